@@ -0,0 +1,308 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// An AlignedCrosser matches up the hidden units of two
+// networks before performing cross-over, so that units
+// which play the same role are crossed with each other.
+//
+// Hidden units are matched with the Hungarian algorithm,
+// either by the correlation of their activations on the
+// current batch or by the similarity of their weights.
+// The source is never modified; a permuted copy of it is
+// passed to the underlying Crosser.
+//
+// Alignment is supported for anynet.Net objects made of
+// *anynet.FC layers, optionally separated by layers
+// without parameters and by per-unit *anynet.Affine or
+// *anyconv.BatchNorm layers.
+// Other entities are passed to the Crosser unchanged.
+type AlignedCrosser struct {
+	// Crosser performs cross-over after alignment.
+	// If nil, a NeuronalCrosser is used.
+	Crosser Crosser
+
+	// Activations indicates that units should be matched
+	// by activation correlation when an *anyff.Batch is
+	// available.
+	// Otherwise, or if no batch is available, units are
+	// matched by weight similarity.
+	Activations bool
+
+	batch *anyff.Batch
+	rng   rng
+}
+
+// SetRand sets the random number generator.
+func (a *AlignedCrosser) SetRand(r *rand.Rand) {
+	a.rng.Rand = r
+	setRand(a.Crosser, r)
+}
+
+// SetBatch sets the batch used for activations.
+func (a *AlignedCrosser) SetBatch(b anysgd.Batch) {
+	a.batch, _ = b.(*anyff.Batch)
+}
+
+// Cross performs cross-over.
+// It panics if the entities are unsupported.
+func (a *AlignedCrosser) Cross(dest, source Entity, keep float64) {
+	if err := a.CrossErr(dest, source, keep); err != nil {
+		panic(err)
+	}
+}
+
+// CrossErr aligns the source to dest and performs
+// cross-over.
+func (a *AlignedCrosser) CrossErr(dest, source Entity, keep float64) error {
+	defer touchEntity(dest)
+	crosser := a.Crosser
+	if crosser == nil {
+		crosser = &NeuronalCrosser{rng: a.rng}
+	}
+	destNet, ok1 := netEntityNet(dest)
+	sourceNet, ok2 := netEntityNet(source)
+	if !ok1 || !ok2 || !alignableNets(destNet, sourceNet) {
+		return cross(crosser, dest, source, keep)
+	}
+	aligned, err := source.(*NetEntity).Copy()
+	if err != nil {
+		return err
+	}
+	alignedNet, _ := netEntityNet(aligned)
+	a.align(destNet, alignedNet)
+	return cross(crosser, dest, aligned, keep)
+}
+
+// align permutes the hidden units of source in place to
+// match those of dest.
+func (a *AlignedCrosser) align(dest, source anynet.Net) {
+	var destActs, sourceActs []anyvec.Vector
+	if a.Activations && a.batch != nil {
+		destActs = fcActivations(dest, a.batch)
+		sourceActs = fcActivations(source, a.batch)
+	}
+
+	fcIdxs := fcIndices(dest)
+	for i, idx := range fcIdxs[:len(fcIdxs)-1] {
+		destFC := dest[idx].(*anynet.FC)
+		sourceFC := source[idx].(*anynet.FC)
+		var cost [][]float64
+		if destActs != nil {
+			cost = correlationCost(destActs[i], sourceActs[i], destFC.OutCount)
+		} else {
+			cost = weightCost(destFC, sourceFC)
+		}
+		perm := hungarian(cost)
+
+		permuteRows(sourceFC.Weights.Vector, perm)
+		permuteRows(sourceFC.Biases.Vector, perm)
+		for _, layer := range source[idx+1 : fcIdxs[i+1]] {
+			switch layer := layer.(type) {
+			case *anynet.Affine:
+				permuteChannels(layer.Scalers.Vector, perm)
+				permuteChannels(layer.Biases.Vector, perm)
+			case *anyconv.BatchNorm:
+				permuteChannels(layer.Scalers.Vector, perm)
+				permuteChannels(layer.Biases.Vector, perm)
+			}
+		}
+		next := source[fcIdxs[i+1]].(*anynet.FC)
+		permuteColumns(next.Weights.Vector, next.InCount, perm)
+	}
+}
+
+func netEntityNet(e Entity) (anynet.Net, bool) {
+	n, ok := e.(*NetEntity)
+	if !ok {
+		return nil, false
+	}
+	net, ok := n.Parameterizer.(anynet.Net)
+	return net, ok
+}
+
+// alignableNets checks if two networks have the same
+// structure and only contain layers which alignment
+// knows how to permute.
+func alignableNets(n1, n2 anynet.Net) bool {
+	if len(n1) != len(n2) || len(fcIndices(n1)) < 2 {
+		return false
+	}
+	units := -1
+	for i, layer := range n1 {
+		if !sameType(layer, n2[i]) {
+			return false
+		}
+		switch layer := layer.(type) {
+		case *anynet.FC:
+			other := n2[i].(*anynet.FC)
+			if layer.InCount != other.InCount || layer.OutCount != other.OutCount {
+				return false
+			}
+			units = layer.OutCount
+		case *anynet.Affine:
+			if !perUnit(units, layer.Scalers.Vector, layer.Biases.Vector) {
+				return false
+			}
+		case *anyconv.BatchNorm:
+			if !perUnit(units, layer.Scalers.Vector, layer.Biases.Vector) {
+				return false
+			}
+		default:
+			if p, ok := layer.(anynet.Parameterizer); ok && len(p.Parameters()) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// perUnit checks that per-channel vectors either have one
+// entry per unit or a single shared entry.
+func perUnit(units int, vecs ...anyvec.Vector) bool {
+	for _, v := range vecs {
+		if v.Len() != units && v.Len() != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func fcIndices(net anynet.Net) []int {
+	var res []int
+	for i, layer := range net {
+		if _, ok := layer.(*anynet.FC); ok {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// fcActivations computes the outputs of every FC layer
+// in a network.
+func fcActivations(net anynet.Net, b *anyff.Batch) []anyvec.Vector {
+	var res []anyvec.Vector
+	var out anydiff.Res = b.Inputs
+	for _, layer := range net {
+		out = layer.Apply(out, b.Num)
+		if _, ok := layer.(*anynet.FC); ok {
+			res = append(res, out.Output())
+		}
+	}
+	return res
+}
+
+// correlationCost computes the negative correlation of
+// every pair of units over a batch of activations.
+func correlationCost(dest, source anyvec.Vector, units int) [][]float64 {
+	destUnits := normalizedUnits(numericFloats(dest.Data()), units)
+	sourceUnits := normalizedUnits(numericFloats(source.Data()), units)
+	cost := make([][]float64, units)
+	for i, d := range destUnits {
+		cost[i] = make([]float64, units)
+		for j, s := range sourceUnits {
+			var corr float64
+			for k, x := range d {
+				corr += x * s[k]
+			}
+			cost[i][j] = -corr
+		}
+	}
+	return cost
+}
+
+// normalizedUnits splits row-major activations into one
+// zero-mean, unit-norm series per unit.
+func normalizedUnits(acts []float64, units int) [][]float64 {
+	num := len(acts) / units
+	res := make([][]float64, units)
+	for i := range res {
+		series := make([]float64, num)
+		var mean float64
+		for j := range series {
+			series[j] = acts[j*units+i]
+			mean += series[j] / float64(num)
+		}
+		var norm float64
+		for j := range series {
+			series[j] -= mean
+			norm += series[j] * series[j]
+		}
+		if norm > 0 {
+			scale := 1 / math.Sqrt(norm)
+			for j := range series {
+				series[j] *= scale
+			}
+		}
+		res[i] = series
+	}
+	return res
+}
+
+// weightCost computes the squared distance between the
+// incoming weights and biases of every pair of units.
+func weightCost(dest, source *anynet.FC) [][]float64 {
+	destWeights := numericFloats(dest.Weights.Vector.Data())
+	sourceWeights := numericFloats(source.Weights.Vector.Data())
+	destBiases := numericFloats(dest.Biases.Vector.Data())
+	sourceBiases := numericFloats(source.Biases.Vector.Data())
+	cost := make([][]float64, dest.OutCount)
+	for i := range cost {
+		cost[i] = make([]float64, dest.OutCount)
+		d := destWeights[i*dest.InCount : (i+1)*dest.InCount]
+		for j := range cost[i] {
+			s := sourceWeights[j*dest.InCount : (j+1)*dest.InCount]
+			diff := destBiases[i] - sourceBiases[j]
+			dist := diff * diff
+			for k, x := range d {
+				diff = x - s[k]
+				dist += diff * diff
+			}
+			cost[i][j] = dist
+		}
+	}
+	return cost
+}
+
+// permuteRows sets row i of a matrix to its old row
+// perm[i].
+func permuteRows(v anyvec.Vector, perm []int) {
+	data := numericFloats(v.Data())
+	cols := len(data) / len(perm)
+	res := make([]float64, len(data))
+	for i, j := range perm {
+		copy(res[i*cols:(i+1)*cols], data[j*cols:(j+1)*cols])
+	}
+	v.SetData(v.Creator().MakeNumericList(res))
+}
+
+// permuteColumns sets column i of a matrix to its old
+// column perm[i].
+func permuteColumns(v anyvec.Vector, cols int, perm []int) {
+	data := numericFloats(v.Data())
+	res := make([]float64, len(data))
+	for row := 0; row < len(data)/cols; row++ {
+		for i, j := range perm {
+			res[row*cols+i] = data[row*cols+j]
+		}
+	}
+	v.SetData(v.Creator().MakeNumericList(res))
+}
+
+// permuteChannels permutes a per-unit vector, leaving
+// shared single-entry vectors untouched.
+func permuteChannels(v anyvec.Vector, perm []int) {
+	if v.Len() == len(perm) {
+		permuteRows(v, perm)
+	}
+}
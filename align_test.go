@@ -0,0 +1,161 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestHungarian(t *testing.T) {
+	for trial := 0; trial < 20; trial++ {
+		n := rand.Intn(5) + 1
+		cost := make([][]float64, n)
+		for i := range cost {
+			cost[i] = make([]float64, n)
+			for j := range cost[i] {
+				cost[i][j] = rand.NormFloat64()
+			}
+		}
+		actual := assignmentCost(cost, hungarian(cost))
+		expected := math.Inf(1)
+		forEachPerm(rand.Perm(n), 0, func(perm []int) {
+			expected = math.Min(expected, assignmentCost(cost, perm))
+		})
+		if math.Abs(actual-expected) > 1e-8 {
+			t.Errorf("trial %d: expected cost %f but got %f", trial, expected, actual)
+		}
+	}
+}
+
+func TestHungarianNonFinite(t *testing.T) {
+	cost := [][]float64{
+		{math.NaN(), 1, 2},
+		{1, math.Inf(1), 3},
+		{2, 3, math.Inf(-1)},
+	}
+	perm := hungarian(cost)
+	for i, j := range perm {
+		if x := cost[i][j]; math.IsNaN(x) || math.IsInf(x, 0) {
+			t.Errorf("row %d assigned to non-finite cost", i)
+		}
+	}
+}
+
+func TestAlignedCrosserNaN(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	dest := anynet.Net{
+		anynet.NewFC(c, 3, 4),
+		anynet.Tanh,
+		anynet.NewFC(c, 4, 2),
+	}
+	source, err := (&NetEntity{Parameterizer: dest}).Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := source.(*NetEntity).Parameters()[0].Vector
+	weights.Slice(0, 1).Scale(c.MakeNumeric(math.NaN()))
+
+	destEntity, _ := (&NetEntity{Parameterizer: dest}).Copy()
+	done := make(chan error, 1)
+	go func() {
+		done <- (&AlignedCrosser{}).CrossErr(destEntity, source, 0.5)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("alignment did not terminate")
+	}
+}
+
+func TestAlignedCrosser(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	dest := anynet.Net{
+		anynet.NewFC(c, 3, 5),
+		anynet.Tanh,
+		anynet.NewAffine(c, 2, 0),
+		anynet.NewFC(c, 5, 2),
+	}
+
+	// The source computes the same function with permuted
+	// hidden units.
+	perm := rand.Perm(5)
+	source, err := (&NetEntity{Parameterizer: dest}).Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceNet := source.(*NetEntity).Parameterizer.(anynet.Net)
+	permuteRows(sourceNet[0].(*anynet.FC).Weights.Vector, perm)
+	permuteRows(sourceNet[0].(*anynet.FC).Biases.Vector, perm)
+	permuteColumns(sourceNet[3].(*anynet.FC).Weights.Vector, 5, perm)
+	original := source.(*NetEntity).Parameters()[0].Vector.Copy()
+
+	inputs := c.MakeVector(3 * 10)
+	for i := 0; i < 3*10; i++ {
+		inputs.Slice(i, i+1).AddScalar(rand.NormFloat64())
+	}
+	batch := &anyff.Batch{Inputs: anydiff.NewConst(inputs), Num: 10}
+
+	for _, activations := range []bool{false, true} {
+		destEntity, _ := (&NetEntity{Parameterizer: dest}).Copy()
+		crosser := &AlignedCrosser{Activations: activations}
+		crosser.SetBatch(batch)
+		if err := crosser.CrossErr(destEntity, source, 0.5); err != nil {
+			t.Fatal(err)
+		}
+
+		// Aligned units are identical, so cross-over should
+		// have no effect.
+		destParams := destEntity.(*NetEntity).Parameters()
+		for i, p := range (&NetEntity{Parameterizer: dest}).Parameters() {
+			diff := p.Vector.Copy()
+			diff.Sub(destParams[i].Vector)
+			if max := anyvecMaxAbs(diff); max > 1e-8 {
+				t.Errorf("activations=%v: parameter %d changed by %f", activations, i, max)
+			}
+		}
+	}
+
+	after := source.(*NetEntity).Parameters()[0].Vector.Copy()
+	after.Sub(original)
+	if anyvecMaxAbs(after) != 0 {
+		t.Error("source was modified")
+	}
+}
+
+func assignmentCost(cost [][]float64, perm []int) float64 {
+	var res float64
+	for i, j := range perm {
+		res += cost[i][j]
+	}
+	return res
+}
+
+func forEachPerm(perm []int, start int, f func([]int)) {
+	if start == len(perm) {
+		f(perm)
+		return
+	}
+	for i := start; i < len(perm); i++ {
+		perm[start], perm[i] = perm[i], perm[start]
+		forEachPerm(perm, start+1, f)
+		perm[start], perm[i] = perm[i], perm[start]
+	}
+}
+
+func anyvecMaxAbs(v anyvec.Vector) float64 {
+	var res float64
+	for _, x := range numericFloats(v.Data()) {
+		res = math.Max(res, math.Abs(x))
+	}
+	return res
+}
@@ -0,0 +1,126 @@
+package leea
+
+// AgeLayers implements the age-layered population
+// structure (ALPS).
+//
+// The population is split evenly into layers, and each
+// layer has a maximum age.
+// Individuals only compete with others in their own
+// layer, and individuals which grow too old for their
+// layer try to move up to the next layer.
+// Every AgeGap generations, the bottom layer is replaced
+// with freshly initialized individuals.
+type AgeLayers struct {
+	// NumLayers is the number of layers.
+	NumLayers int
+
+	// AgeGap is the number of generations between
+	// re-seeding the bottom layer.
+	// It is also the unit for layer age limits, and must
+	// be positive.
+	AgeGap int
+
+	// NewEntity creates a new, randomly initialized
+	// entity for the bottom layer.
+	NewEntity func() Entity
+}
+
+// AgeLimit returns the maximum age for individuals in the
+// given layer, using a polynomial aging scheme.
+//
+// The top layer has no age limit, indicated by -1.
+func (a *AgeLayers) AgeLimit(layer int) int {
+	if layer >= a.NumLayers-1 {
+		return -1
+	} else if layer < 2 {
+		return a.AgeGap * (layer + 1)
+	}
+	return a.AgeGap * layer * layer
+}
+
+// Layers splits the population into its layers.
+func (a *AgeLayers) Layers(pop []*FitEntity) [][]*FitEntity {
+	var res [][]*FitEntity
+	size := len(pop) / a.NumLayers
+	for i := 0; i < a.NumLayers; i++ {
+		end := (i + 1) * size
+		if i == a.NumLayers-1 {
+			end = len(pop)
+		}
+		res = append(res, pop[i*size:end])
+	}
+	return res
+}
+
+// reproduce promotes old individuals and performs
+// selection within each layer, returning the layers.
+func (a *AgeLayers) reproduce(t *Trainer) [][]*FitEntity {
+	layers := a.Layers(t.Population)
+
+	// Promote old individuals from the top down, so that
+	// nobody is promoted twice.
+	for i := len(layers) - 2; i >= 0; i-- {
+		limit := a.AgeLimit(i)
+		for _, e := range layers[i] {
+			if e.Age < limit {
+				continue
+			}
+			worst := layers[i+1][0]
+			for _, e1 := range layers[i+1][1:] {
+				if e1.RunningFitness() < worst.RunningFitness() {
+					worst = e1
+				}
+			}
+			if e.RunningFitness() > worst.RunningFitness() {
+				worst.set(e)
+			}
+		}
+	}
+
+	var lastSurvivors []*FitEntity
+	for i, layer := range layers {
+		limit := a.AgeLimit(i)
+		var young, old []*FitEntity
+		for _, e := range layer {
+			if limit >= 0 && e.Age >= limit {
+				old = append(old, e)
+			} else {
+				young = append(young, e)
+			}
+		}
+
+		t.Selector.SetEntities(young, 1)
+		for j := range young {
+			layer[j] = t.Selector.Select()
+		}
+		copy(layer[len(young):], old)
+
+		n := t.survivorCountFor(len(layer))
+		if n > len(young) {
+			n = len(young)
+		}
+		parents := append(append([]*FitEntity{}, layer[:n]...), lastSurvivors...)
+		for _, e := range layer[n:] {
+			if len(parents) == 0 {
+				e.Entity.Set(a.NewEntity())
+				e.reset()
+			} else {
+				e.set(parents[t.rng().Intn(len(parents))])
+			}
+		}
+		lastSurvivors = layer[:n]
+	}
+
+	return layers
+}
+
+// reseed replaces the bottom layer if necessary.
+func (a *AgeLayers) reseed(t *Trainer) {
+	if (t.Generation+1)%a.AgeGap != 0 {
+		return
+	}
+	for _, e := range a.Layers(t.Population)[0] {
+		e.Entity.Set(a.NewEntity())
+		e.reset()
+	}
+}
@@ -0,0 +1,80 @@
+package leea
+
+import "testing"
+
+func TestAgeLayersLimits(t *testing.T) {
+	layers := &AgeLayers{NumLayers: 4, AgeGap: 5}
+	expected := []int{5, 10, 20, -1}
+	for i, x := range expected {
+		if actual := layers.AgeLimit(i); actual != x {
+			t.Errorf("layer %d: expected limit %d but got %d", i, x, actual)
+		}
+	}
+
+	pop := make([]*FitEntity, 10)
+	var total int
+	for i, layer := range layers.Layers(pop) {
+		total += len(layer)
+		if i < 3 && len(layer) != 2 {
+			t.Errorf("layer %d: expected 2 members but got %d", i, len(layer))
+		}
+	}
+	if total != len(pop) {
+		t.Errorf("layers cover %d of %d individuals", total, len(pop))
+	}
+}
+
+func TestAgeLayersTrainer(t *testing.T) {
+	var reseeds int
+	trainer := &Trainer{
+		Evaluator: &ObjectiveEvaluator{
+			Objective: func(x []float64) float64 { return -x[0] * x[0] },
+		},
+		Selector:          &TournamentSelector{Size: 2, Prob: 1},
+		Mutator:           &AddMutator{Stddev: &ExpSchedule{Init: 0.1}},
+		Crosser:           &UniformCrosser{},
+		CrossOverSchedule: &ExpSchedule{Baseline: 0.5},
+		SurvivalRatio:     0.5,
+		AgeLayers: &AgeLayers{
+			NumLayers: 3,
+			AgeGap:    3,
+			NewEntity: func() Entity {
+				reseeds++
+				return NewVectorEntity([]float64{5})
+			},
+		},
+	}
+	for i := 0; i < 12; i++ {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity([]float64{5}),
+		})
+	}
+	for gen := 0; gen < 9; gen++ {
+		if err := trainer.generation(); err != nil {
+			t.Fatal(err)
+		}
+		if len(trainer.Population) != 12 {
+			t.Fatalf("population size changed to %d", len(trainer.Population))
+		}
+		if trainer.Generation%3 == 0 {
+			for _, e := range trainer.AgeLayers.Layers(trainer.Population)[0] {
+				if e.Age != 0 || e.Scale != 0 {
+					t.Errorf("generation %d: bottom layer was not reseeded",
+						trainer.Generation)
+				}
+			}
+		}
+	}
+	if reseeds != 3*4 {
+		t.Errorf("expected 12 new entities but got %d", reseeds)
+	}
+	var old bool
+	for _, e := range trainer.AgeLayers.Layers(trainer.Population)[2] {
+		if e.Age > 3 {
+			old = true
+		}
+	}
+	if !old {
+		t.Error("no old individuals reached the top layer")
+	}
+}
@@ -0,0 +1,291 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/essentials"
+)
+
+// An Augmenter applies random transformations to a
+// mini-batch of samples.
+type Augmenter interface {
+	// Augment returns a transformed copy of the samples.
+	// The original samples must not be modified.
+	Augment(s anysgd.SampleList, r *rand.Rand) (anysgd.SampleList, error)
+}
+
+// An AugmentSampleSource sits between a SampleSource and
+// a Fetcher, applying random transformations to every
+// mini-batch.
+//
+// Each mini-batch gets a fresh random generator seeded
+// from the global math/rand generator, which the Trainer
+// uses for all of its randomness.
+//
+// It is a WrapperSource, so the Trainer treats it as a
+// ProgressSource, FeedbackSource, or BatchResizer exactly
+// when the underlying source is one.
+type AugmentSampleSource struct {
+	// Source produces the original mini-batches.
+	Source SampleSource
+
+	// Augmenters are applied to each mini-batch in order.
+	Augmenters []Augmenter
+}
+
+// MiniBatch produces an augmented mini-batch.
+func (a *AugmentSampleSource) MiniBatch() (anysgd.SampleList, error) {
+	batch, err := a.Source.MiniBatch()
+	if err != nil {
+		return nil, err
+	}
+	gen := rand.New(rand.NewSource(rand.Int63()))
+	for _, aug := range a.Augmenters {
+		batch, err = aug.Augment(batch, gen)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// Unwrap returns the underlying source.
+func (a *AugmentSampleSource) Unwrap() SampleSource {
+	return a.Source
+}
+
+// An ImageAugmenter randomly transforms the input images
+// of feed-forward samples.
+//
+// Images are stored in row-major order with the depth
+// component innermost, as in anyconv.
+// Every transform is disabled when its field is 0.
+type ImageAugmenter struct {
+	Width  int
+	Height int
+	Depth  int
+
+	// Shift is the maximum number of pixels by which an
+	// image is translated along each axis.
+	Shift int
+
+	// Rotation is the maximum angle, in radians, by which
+	// an image is rotated about its center.
+	Rotation float64
+
+	// Elastic is the standard deviation, in pixels, of a
+	// smooth random displacement field.
+	Elastic float64
+
+	// ElasticGrid is the number of cells along each axis
+	// of the grid from which the displacement field is
+	// interpolated.
+	// If 0, 4 is used.
+	ElasticGrid int
+
+	// Dropout is the probability of zeroing out each
+	// pixel.
+	Dropout float64
+}
+
+// Augment transforms the inputs of an anyff.SampleList,
+// producing an anyff.SliceSampleList.
+func (i *ImageAugmenter) Augment(s anysgd.SampleList,
+	r *rand.Rand) (anysgd.SampleList, error) {
+	list, ok := s.(anyff.SampleList)
+	if !ok {
+		return nil, errors.New("image augmentation: not an anyff.SampleList")
+	}
+	res := make(anyff.SliceSampleList, s.Len())
+	for idx := range res {
+		sample, err := list.GetSample(idx)
+		if err != nil {
+			return nil, essentials.AddCtx("image augmentation", err)
+		}
+		in := numericFloats(sample.Input.Data())
+		if len(in) != i.Width*i.Height*i.Depth {
+			return nil, errors.New("image augmentation: input size mismatch")
+		}
+		out := i.transform(in, r)
+		c := sample.Input.Creator()
+		res[idx] = &anyff.Sample{
+			Input:  c.MakeVectorData(c.MakeNumericList(out)),
+			Output: sample.Output,
+		}
+	}
+	return res, nil
+}
+
+func (i *ImageAugmenter) transform(in []float64, r *rand.Rand) []float64 {
+	var shiftX, shiftY, angle float64
+	if i.Shift > 0 {
+		shiftX = float64(r.Intn(2*i.Shift+1) - i.Shift)
+		shiftY = float64(r.Intn(2*i.Shift+1) - i.Shift)
+	}
+	if i.Rotation > 0 {
+		angle = (r.Float64()*2 - 1) * i.Rotation
+	}
+	var field *elasticField
+	if i.Elastic > 0 {
+		field = i.elasticField(r)
+	}
+
+	out := in
+	if shiftX != 0 || shiftY != 0 || angle != 0 || field != nil {
+		out = make([]float64, len(in))
+		centerX, centerY := float64(i.Width-1)/2, float64(i.Height-1)/2
+		sin, cos := math.Sin(angle), math.Cos(angle)
+		for y := 0; y < i.Height; y++ {
+			for x := 0; x < i.Width; x++ {
+				// Map each destination pixel back to the
+				// source image.
+				px := float64(x) - centerX - shiftX
+				py := float64(y) - centerY - shiftY
+				srcX := cos*px + sin*py + centerX
+				srcY := -sin*px + cos*py + centerY
+				if field != nil {
+					dx, dy := field.At(float64(x)/float64(i.Width),
+						float64(y)/float64(i.Height))
+					srcX += dx
+					srcY += dy
+				}
+				for z := 0; z < i.Depth; z++ {
+					out[(y*i.Width+x)*i.Depth+z] = i.interpolate(in, srcX, srcY, z)
+				}
+			}
+		}
+	} else if i.Dropout > 0 {
+		out = append([]float64{}, in...)
+	}
+
+	if i.Dropout > 0 {
+		for pixel := 0; pixel < i.Width*i.Height; pixel++ {
+			if r.Float64() < i.Dropout {
+				for z := 0; z < i.Depth; z++ {
+					out[pixel*i.Depth+z] = 0
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// interpolate samples the image with bilinear
+// interpolation, treating pixels outside of the image as
+// zero.
+func (i *ImageAugmenter) interpolate(in []float64, x, y float64, z int) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	var res float64
+	for _, corner := range [4][3]float64{
+		{x0, y0, (1 - fx) * (1 - fy)},
+		{x0 + 1, y0, fx * (1 - fy)},
+		{x0, y0 + 1, (1 - fx) * fy},
+		{x0 + 1, y0 + 1, fx * fy},
+	} {
+		cx, cy, weight := int(corner[0]), int(corner[1]), corner[2]
+		if weight == 0 || cx < 0 || cy < 0 || cx >= i.Width || cy >= i.Height {
+			continue
+		}
+		res += weight * in[(cy*i.Width+cx)*i.Depth+z]
+	}
+	return res
+}
+
+func (i *ImageAugmenter) elasticField(r *rand.Rand) *elasticField {
+	grid := i.ElasticGrid
+	if grid == 0 {
+		grid = 4
+	}
+	res := &elasticField{
+		Size: grid,
+		DX:   make([]float64, (grid+1)*(grid+1)),
+		DY:   make([]float64, (grid+1)*(grid+1)),
+	}
+	for j := range res.DX {
+		res.DX[j] = r.NormFloat64() * i.Elastic
+		res.DY[j] = r.NormFloat64() * i.Elastic
+	}
+	return res
+}
+
+// An elasticField is a displacement field which is
+// bilinearly interpolated from random displacements on a
+// coarse grid.
+type elasticField struct {
+	Size int
+	DX   []float64
+	DY   []float64
+}
+
+// At computes the displacement at relative coordinates
+// between 0 and 1.
+func (e *elasticField) At(x, y float64) (dx, dy float64) {
+	gx, gy := x*float64(e.Size), y*float64(e.Size)
+	x0, y0 := int(gx), int(gy)
+	if x0 >= e.Size {
+		x0 = e.Size - 1
+	}
+	if y0 >= e.Size {
+		y0 = e.Size - 1
+	}
+	fx, fy := gx-float64(x0), gy-float64(y0)
+	for _, corner := range [4][3]float64{
+		{0, 0, (1 - fx) * (1 - fy)},
+		{1, 0, fx * (1 - fy)},
+		{0, 1, (1 - fx) * fy},
+		{1, 1, fx * fy},
+	} {
+		idx := (y0+int(corner[1]))*(e.Size+1) + x0 + int(corner[0])
+		dx += corner[2] * e.DX[idx]
+		dy += corner[2] * e.DY[idx]
+	}
+	return
+}
+
+// A TextAugmenter randomly substitutes characters in text
+// samples.
+type TextAugmenter struct {
+	// Prob is the probability of substituting each
+	// character.
+	Prob float64
+
+	// Alphabet contains the characters to substitute in.
+	// If nil, printable ASCII characters are used.
+	Alphabet []byte
+
+	// Texts extracts the text of every sample in a list.
+	Texts func(s anysgd.SampleList) [][]byte
+
+	// FromTexts creates a sample list from texts.
+	FromTexts func(texts [][]byte) anysgd.SampleList
+}
+
+// Augment substitutes characters in copies of the texts.
+func (t *TextAugmenter) Augment(s anysgd.SampleList,
+	r *rand.Rand) (anysgd.SampleList, error) {
+	alphabet := t.Alphabet
+	if alphabet == nil {
+		for ch := byte(' '); ch <= '~'; ch++ {
+			alphabet = append(alphabet, ch)
+		}
+	} else if len(alphabet) == 0 {
+		return nil, errors.New("text augmentation: empty alphabet")
+	}
+	var res [][]byte
+	for _, text := range t.Texts(s) {
+		text = append([]byte{}, text...)
+		for i := range text {
+			if r.Float64() < t.Prob {
+				text[i] = alphabet[r.Intn(len(alphabet))]
+			}
+		}
+		res = append(res, text)
+	}
+	return t.FromTexts(res), nil
+}
@@ -0,0 +1,141 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestImageAugmenterShift(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	img := make([]float64, 5*4*2)
+	for i := range img {
+		img[i] = float64(i + 1)
+	}
+	list := anyff.SliceSampleList{&anyff.Sample{
+		Input:  c.MakeVectorData(c.MakeNumericList(img)),
+		Output: c.MakeVector(1),
+	}}
+	aug := &ImageAugmenter{Width: 5, Height: 4, Depth: 2, Shift: 1}
+
+	for trial := 0; trial < 20; trial++ {
+		res, err := aug.Augment(list, rand.New(rand.NewSource(int64(trial))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := res.(anyff.SliceSampleList)[0].Input.Data().([]float64)
+
+		// Find the shift which explains the output.
+		var found bool
+		for dy := -1; dy <= 1 && !found; dy++ {
+			for dx := -1; dx <= 1 && !found; dx++ {
+				found = true
+				for i, actual := range out {
+					z := i % 2
+					x := (i / 2) % 5
+					y := i / 10
+					var expected float64
+					sx, sy := x-dx, y-dy
+					if sx >= 0 && sy >= 0 && sx < 5 && sy < 4 {
+						expected = img[(sy*5+sx)*2+z]
+					}
+					if math.Abs(actual-expected) > 1e-8 {
+						found = false
+						break
+					}
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("trial %d: output is not a shift: %v", trial, out)
+		}
+	}
+
+	if list[0].Input.Data().([]float64)[0] != 1 {
+		t.Error("original sample was modified")
+	}
+}
+
+func TestTextAugmenter(t *testing.T) {
+	texts := [][]byte{[]byte("hello world"), []byte("foo")}
+	aug := &TextAugmenter{
+		Prob:     0.5,
+		Alphabet: []byte("xyz"),
+		Texts: func(s anysgd.SampleList) [][]byte {
+			return s.(byteSampleList)
+		},
+		FromTexts: func(texts [][]byte) anysgd.SampleList {
+			return byteSampleList(texts)
+		},
+	}
+	res, err := aug.Augment(byteSampleList(texts), rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed bool
+	for i, text := range res.(byteSampleList) {
+		if len(text) != len(texts[i]) {
+			t.Fatalf("sample %d: bad length %d", i, len(text))
+		}
+		for j, ch := range text {
+			if ch != texts[i][j] {
+				changed = true
+				if ch != 'x' && ch != 'y' && ch != 'z' {
+					t.Errorf("sample %d: unexpected character %q", i, ch)
+				}
+			}
+		}
+	}
+	if !changed {
+		t.Error("no characters were substituted")
+	}
+	if string(texts[0]) != "hello world" || string(texts[1]) != "foo" {
+		t.Error("original samples were modified")
+	}
+}
+
+func TestAugmentSampleSourceWrapping(t *testing.T) {
+	plain := &AugmentSampleSource{Source: &EpisodeSource{}}
+	if _, ok := feedbackSource(plain); ok {
+		t.Error("plain source should not accept feedback")
+	}
+	if _, ok := batchResizer(plain); ok {
+		t.Error("plain source should not be resizable")
+	}
+
+	hard := &HardSampleSource{Samples: hardTestSamples(4), BatchSize: 2}
+	wrapped := &AugmentSampleSource{Source: &AugmentSampleSource{Source: hard}}
+	if f, ok := feedbackSource(wrapped); !ok || f != hard {
+		t.Error("feedback should reach the wrapped source")
+	}
+	if r, ok := batchResizer(wrapped); !ok || r != hard {
+		t.Error("resizing should reach the wrapped source")
+	}
+
+	// Wrapping a plain source must not require a
+	// SampleEvaluator.
+	trainer := &Trainer{
+		Evaluator: &ObjectiveEvaluator{
+			Objective: func(x []float64) float64 { return -x[0] * x[0] },
+		},
+		Samples:           plain,
+		Fetcher:           &EpisodeSource{},
+		Selector:          &TournamentSelector{Size: 2, Prob: 1},
+		Mutator:           &AddMutator{Stddev: &ExpSchedule{Init: 0.1}},
+		Crosser:           &UniformCrosser{},
+		CrossOverSchedule: &ExpSchedule{Baseline: 0.5},
+		SurvivalRatio:     0.5,
+	}
+	for i := 0; i < 4; i++ {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity([]float64{1}),
+		})
+	}
+	if err := trainer.generation(); err != nil {
+		t.Fatal(err)
+	}
+}
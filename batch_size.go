@@ -0,0 +1,120 @@
+package leea
+
+import "math"
+
+// DefaultNoiseCooldown is the default Cooldown for a
+// NoiseBatchSizer.
+const DefaultNoiseCooldown = 5
+
+// DefaultNoiseGrowth is the default Growth for a
+// NoiseBatchSizer.
+const DefaultNoiseGrowth = 2
+
+// A BatchSizer determines the mini-batch size for each
+// generation of a Trainer.
+type BatchSizer interface {
+	// BatchSize returns the batch size for the current
+	// generation.
+	// It should not modify the BatchSizer, so it is safe
+	// to call at any time.
+	BatchSize(t *Trainer) int
+}
+
+// An AdaptiveBatchSizer is a BatchSizer whose state
+// depends on the progress of training.
+// The Trainer calls Update once per generation, before
+// calling BatchSize.
+type AdaptiveBatchSizer interface {
+	BatchSizer
+
+	Update(t *Trainer)
+}
+
+// A ScheduleBatchSizer uses a Schedule to determine the
+// batch size for each generation.
+// Schedule values are rounded to the nearest integer.
+type ScheduleBatchSizer struct {
+	Schedule Schedule
+}
+
+// BatchSize returns the scheduled batch size.
+func (s *ScheduleBatchSizer) BatchSize(t *Trainer) int {
+	return int(s.Schedule.ValueAtTime(t.Generation) + 0.5)
+}
+
+// A NoiseBatchSizer grows the batch size whenever the
+// population's fitness noise-to-signal ratio exceeds a
+// threshold.
+//
+// See Trainer.NoiseToSignal for how the ratio is
+// computed.
+// The noise is estimated from each individual's history
+// of evaluations, so it is always 0 (and the batch never
+// grows) when the Trainer's Inheritance is 0 and every
+// individual is scored on a single batch per generation.
+// Use a BatchWindow in that case.
+type NoiseBatchSizer struct {
+	// Init is the initial batch size.
+	Init int
+
+	// Max is the maximum batch size.
+	// If 0, there is no maximum.
+	Max int
+
+	// Growth is the factor by which the batch size is
+	// multiplied when it grows.
+	// The batch always grows by at least one sample, so
+	// values at or below 1 grow it one sample at a time.
+	// If 0, DefaultNoiseGrowth is used.
+	Growth float64
+
+	// Threshold is the noise-to-signal ratio above which
+	// the batch size grows.
+	Threshold float64
+
+	// Cooldown is the minimum number of generations
+	// between growths, giving fitness estimates time to
+	// adjust to the new batch size.
+	// If 0, DefaultNoiseCooldown is used.
+	Cooldown int
+
+	cur        int
+	lastGrowth int
+}
+
+// BatchSize returns the current batch size.
+func (n *NoiseBatchSizer) BatchSize(t *Trainer) int {
+	if n.cur == 0 {
+		return n.Init
+	}
+	return n.cur
+}
+
+// Update grows the batch size if the noise-to-signal
+// ratio is too high and the cooldown has passed.
+func (n *NoiseBatchSizer) Update(t *Trainer) {
+	if n.cur == 0 {
+		n.cur = n.Init
+		n.lastGrowth = t.Generation
+	}
+	cooldown := n.Cooldown
+	if cooldown == 0 {
+		cooldown = DefaultNoiseCooldown
+	}
+	if t.Generation-n.lastGrowth < cooldown || t.NoiseToSignal() <= n.Threshold {
+		return
+	}
+	growth := n.Growth
+	if growth == 0 {
+		growth = DefaultNoiseGrowth
+	}
+	next := int(math.Ceil(float64(n.cur) * growth))
+	if next <= n.cur {
+		next = n.cur + 1
+	}
+	n.cur = next
+	if n.Max != 0 && n.cur > n.Max {
+		n.cur = n.Max
+	}
+	n.lastGrowth = t.Generation
+}
@@ -0,0 +1,69 @@
+package leea
+
+import "testing"
+
+func TestNoiseBatchSizer(t *testing.T) {
+	trainer := &Trainer{}
+	for _, fit := range []float64{1, 2, 3} {
+		// Every individual has variance 4, while the fitness
+		// variance is 2/3.
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Fitness:   fit,
+			SqFitness: fit*fit + 4,
+			Scale:     1,
+		})
+	}
+	sizer := &NoiseBatchSizer{
+		Init:      10,
+		Max:       35,
+		Growth:    2,
+		Threshold: 1,
+		Cooldown:  3,
+	}
+	for i := 0; i < 3; i++ {
+		if size := sizer.BatchSize(trainer); size != 10 {
+			t.Fatalf("BatchSize should not grow the batch (got %d)", size)
+		}
+	}
+
+	expected := []int{10, 10, 10, 20, 20, 20, 35, 35, 35, 35}
+	for gen, size := range expected {
+		trainer.Generation = gen
+		sizer.Update(trainer)
+		if actual := sizer.BatchSize(trainer); actual != size {
+			t.Errorf("generation %d: expected %d but got %d", gen, size, actual)
+		}
+	}
+
+	defaults := &NoiseBatchSizer{Init: 10, Threshold: 1}
+	slow := &NoiseBatchSizer{Init: 10, Growth: 0.5, Threshold: 1, Cooldown: 1}
+	for gen := 0; gen < 6; gen++ {
+		trainer.Generation = gen
+		defaults.Update(trainer)
+		slow.Update(trainer)
+	}
+	if size := defaults.BatchSize(trainer); size != 20 {
+		t.Errorf("default growth: expected 20 but got %d", size)
+	}
+	if size := slow.BatchSize(trainer); size != 15 {
+		t.Errorf("slow growth: expected 15 but got %d", size)
+	}
+
+	quiet := &NoiseBatchSizer{Init: 10, Growth: 2, Threshold: 10, Cooldown: 1}
+	for gen := 0; gen < 5; gen++ {
+		trainer.Generation = gen
+		quiet.Update(trainer)
+	}
+	if size := quiet.BatchSize(trainer); size != 10 {
+		t.Errorf("batch grew below threshold: %d", size)
+	}
+}
+
+func TestScheduleBatchSizer(t *testing.T) {
+	sizer := &ScheduleBatchSizer{Schedule: &ExpSchedule{Init: 10, DecayRate: 0.5, Baseline: 4}}
+	for gen, expected := range []int{14, 9, 7} {
+		if actual := sizer.BatchSize(&Trainer{Generation: gen}); actual != expected {
+			t.Errorf("generation %d: expected %d but got %d", gen, expected, actual)
+		}
+	}
+}
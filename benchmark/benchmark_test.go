@@ -0,0 +1,139 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/leea"
+)
+
+func TestFunctionOptima(t *testing.T) {
+	for _, f := range []*Function{Sphere(4), Rastrigin(4), Ackley(4), Griewank(4)} {
+		if val := f.F(make([]float64, 4)); math.Abs(val) > 1e-8 {
+			t.Errorf("%s: expected 0 at origin but got %f", f.Name, val)
+		}
+	}
+	if val := Rosenbrock(4).F([]float64{1, 1, 1, 1}); val != 0 {
+		t.Errorf("rosenbrock: expected 0 at ones but got %f", val)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	runner := &Runner{
+		Problem:        Sphere(3),
+		NewTrainer:     testTrainer,
+		PopulationSize: 20,
+		Generations:    40,
+		Seeds:          []int64{1, 2, 3},
+		Target:         -0.1,
+	}
+	res, err := runner.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Runs) != 3 {
+		t.Fatalf("expected 3 runs but got %d", len(res.Runs))
+	}
+	var successes int
+	for i, run := range res.Runs {
+		if run.Seed != runner.Seeds[i] {
+			t.Errorf("run %d: expected seed %d but got %d", i, runner.Seeds[i], run.Seed)
+		}
+		if run.Success {
+			successes++
+			if run.Final < runner.Target {
+				t.Errorf("seed %d: succeeded with score %f", run.Seed, run.Final)
+			}
+		} else if run.Generations != runner.Generations {
+			t.Errorf("seed %d: stopped early at generation %d", run.Seed, run.Generations)
+		}
+	}
+	if successes == 0 {
+		t.Errorf("no successful runs: %s", res)
+	} else if res.SuccessRate != float64(successes)/3 {
+		t.Errorf("unexpected success rate: %f", res.SuccessRate)
+	}
+}
+
+func TestRunnerReproducible(t *testing.T) {
+	for _, problem := range []Problem{Sphere(3), XOR()} {
+		runner := &Runner{
+			Problem:        problem,
+			NewTrainer:     testTrainer,
+			PopulationSize: 10,
+			Generations:    10,
+			Seeds:          []int64{1, 2},
+		}
+		res1, err := runner.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res2, err := runner.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, run := range res1.Runs {
+			if *run != *res2.Runs[i] {
+				t.Errorf("%T: seed %d gave %v then %v", problem, run.Seed, *run,
+					*res2.Runs[i])
+			}
+		}
+	}
+}
+
+func TestConfigureSeeds(t *testing.T) {
+	for _, problem := range []Problem{Sphere(3), XOR()} {
+		var params [3][]float64
+		for i, seed := range []int64{1, 1, 2} {
+			trainer := testTrainer()
+			problem.Configure(trainer, 3, rand.New(rand.NewSource(seed)))
+			for _, e := range trainer.Population {
+				for _, p := range e.Entity.(anynet.Parameterizer).Parameters() {
+					params[i] = append(params[i], p.Vector.Data().([]float64)...)
+				}
+			}
+		}
+		for i, x := range params[0] {
+			if x != params[1][i] {
+				t.Errorf("%T: same seed gave different populations", problem)
+				break
+			}
+		}
+		same := true
+		for i, x := range params[0] {
+			if x != params[2][i] {
+				same = false
+			}
+		}
+		if same {
+			t.Errorf("%T: different seeds gave the same population", problem)
+		}
+	}
+}
+
+func TestTasks(t *testing.T) {
+	for _, task := range []*Task{XOR(), Parity(3), Spirals(20), Regression(10)} {
+		trainer := testTrainer()
+		task.Configure(trainer, 10, rand.New(rand.NewSource(1)))
+		if err := trainer.Evolve(func() bool { return trainer.Generation < 2 }); err != nil {
+			t.Fatalf("%s: %s", task.Name, err)
+		}
+		score := task.Score(trainer)
+		if math.IsNaN(score) || (task.Classification && (score < 0 || score > 1)) {
+			t.Errorf("%s: unexpected score %f", task.Name, score)
+		}
+	}
+}
+
+func testTrainer() *leea.Trainer {
+	return &leea.Trainer{
+		Selector:          &leea.TournamentSelector{Size: 3, Prob: 1},
+		Mutator:           &leea.AddMutator{Stddev: &leea.ExpSchedule{Init: 0.5, DecayRate: 0.95}},
+		Crosser:           &leea.UniformCrosser{},
+		CrossOverSchedule: &leea.ExpSchedule{Baseline: 0.5},
+		SurvivalRatio:     0.3,
+		Elitism:           1,
+	}
+}
@@ -0,0 +1,145 @@
+// Package benchmark provides standard problems for
+// comparing LEEA configurations, along with a Runner that
+// measures how well a configuration solves them.
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/leea"
+)
+
+// A Function is a classic test function to be minimized.
+// Every built-in function has a global minimum of 0.
+//
+// The Score of a Trainer is the negative value of the
+// function at its best entity.
+type Function struct {
+	Name string
+	Dim  int
+
+	// InitRange is the half-width of the hypercube, centered
+	// at the origin, from which initial entities are drawn.
+	InitRange float64
+
+	F func(x []float64) float64
+}
+
+// Sphere creates the sphere function, the sum of the
+// squares of the coordinates.
+func Sphere(dim int) *Function {
+	return &Function{
+		Name:      "sphere",
+		Dim:       dim,
+		InitRange: 5.12,
+		F: func(x []float64) float64 {
+			var res float64
+			for _, c := range x {
+				res += c * c
+			}
+			return res
+		},
+	}
+}
+
+// Rastrigin creates the highly multimodal Rastrigin
+// function.
+func Rastrigin(dim int) *Function {
+	return &Function{
+		Name:      "rastrigin",
+		Dim:       dim,
+		InitRange: 5.12,
+		F: func(x []float64) float64 {
+			res := 10 * float64(len(x))
+			for _, c := range x {
+				res += c*c - 10*math.Cos(2*math.Pi*c)
+			}
+			return res
+		},
+	}
+}
+
+// Rosenbrock creates the Rosenbrock function, whose
+// minimum lies at the end of a narrow curved valley.
+func Rosenbrock(dim int) *Function {
+	return &Function{
+		Name:      "rosenbrock",
+		Dim:       dim,
+		InitRange: 2.048,
+		F: func(x []float64) float64 {
+			var res float64
+			for i := 0; i < len(x)-1; i++ {
+				a := x[i+1] - x[i]*x[i]
+				b := 1 - x[i]
+				res += 100*a*a + b*b
+			}
+			return res
+		},
+	}
+}
+
+// Ackley creates the Ackley function, which is nearly flat
+// far away from its minimum.
+func Ackley(dim int) *Function {
+	return &Function{
+		Name:      "ackley",
+		Dim:       dim,
+		InitRange: 32.768,
+		F: func(x []float64) float64 {
+			var sq, cos float64
+			for _, c := range x {
+				sq += c * c
+				cos += math.Cos(2 * math.Pi * c)
+			}
+			n := float64(len(x))
+			return -20*math.Exp(-0.2*math.Sqrt(sq/n)) - math.Exp(cos/n) + 20 + math.E
+		},
+	}
+}
+
+// Griewank creates the Griewank function.
+func Griewank(dim int) *Function {
+	return &Function{
+		Name:      "griewank",
+		Dim:       dim,
+		InitRange: 600,
+		F: func(x []float64) float64 {
+			sum, prod := 0.0, 1.0
+			for i, c := range x {
+				sum += c * c / 4000
+				prod *= math.Cos(c / math.Sqrt(float64(i+1)))
+			}
+			return sum - prod + 1
+		},
+	}
+}
+
+// Configure sets up a Trainer to minimize the function
+// with a population of VectorEntity instances.
+func (f *Function) Configure(t *leea.Trainer, popSize int, r *rand.Rand) {
+	t.Evaluator = &leea.ObjectiveEvaluator{Objective: f.F, Minimize: true}
+	t.Samples = nil
+	t.Fetcher = nil
+	t.Population = nil
+	for i := 0; i < popSize; i++ {
+		x := make([]float64, f.Dim)
+		for j := range x {
+			x[j] = (r.Float64()*2 - 1) * f.InitRange
+		}
+		t.Population = append(t.Population, &leea.FitEntity{
+			Entity: leea.NewVectorEntity(x),
+		})
+	}
+}
+
+// Score computes the negative value of the function at
+// the best entity.
+func (f *Function) Score(t *leea.Trainer) float64 {
+	return -f.F(t.BestEntity().Entity.(*leea.VectorEntity).Floats())
+}
+
+// Target is -0.01, i.e. a function value of 0.01.
+func (f *Function) Target() float64 {
+	return -0.01
+}
@@ -0,0 +1,157 @@
+package benchmark
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/leea"
+)
+
+// A Problem is a benchmark which a Trainer can be
+// configured to solve.
+type Problem interface {
+	// Configure sets the Evaluator, Samples, Fetcher, and
+	// Population of a Trainer.
+	// The initial population is drawn from r.
+	Configure(t *leea.Trainer, popSize int, r *rand.Rand)
+
+	// Score measures the best entity of the Trainer.
+	// Higher scores are better.
+	Score(t *leea.Trainer) float64
+
+	// Target returns the score at which the problem is
+	// considered solved.
+	Target() float64
+}
+
+// A Runner evaluates a Trainer configuration on a Problem
+// by running it with several random seeds.
+//
+// Each seed determines the initial population of a run
+// and is used for the Trainer's Rand, so runs with the
+// same seed are identical as long as the Trainer's
+// components are deterministic given the Rand.
+type Runner struct {
+	Problem Problem
+
+	// NewTrainer creates a Trainer with every setting
+	// except for the ones set by Problem.Configure.
+	NewTrainer func() *leea.Trainer
+
+	// PopulationSize is the number of entities.
+	PopulationSize int
+
+	// Generations is the maximum number of generations
+	// per run.
+	Generations int
+
+	// Seeds are the random seeds, one per run.
+	Seeds []int64
+
+	// Target is the score at which a run succeeds and
+	// stops early.
+	// If 0, the Problem's Target is used.
+	Target float64
+}
+
+// Run performs every run.
+func (r *Runner) Run() (*Result, error) {
+	res := &Result{}
+	for _, seed := range r.Seeds {
+		run, err := r.runSeed(seed)
+		if err != nil {
+			return nil, err
+		}
+		res.Runs = append(res.Runs, run)
+	}
+	res.summarize()
+	return res, nil
+}
+
+func (r *Runner) runSeed(seed int64) (*RunResult, error) {
+	target := r.Target
+	if target == 0 {
+		target = r.Problem.Target()
+	}
+	t := r.NewTrainer()
+	gen := rand.New(rand.NewSource(seed))
+	r.Problem.Configure(t, r.PopulationSize, gen)
+	t.Rand = gen
+
+	res := &RunResult{Seed: seed}
+	err := t.Evolve(func() bool {
+		if t.Generation == 0 {
+			return true
+		}
+		res.Final = r.Problem.Score(t)
+		res.Generations = t.Generation
+		if res.Final >= target {
+			res.Success = true
+			return false
+		}
+		return t.Generation < r.Generations
+	})
+	if err != nil {
+		return nil, fmt.Errorf("run seed %d: %s", seed, err)
+	}
+	return res, nil
+}
+
+// A RunResult is the outcome of one run.
+type RunResult struct {
+	Seed int64
+
+	// Success indicates that the target was reached.
+	Success bool
+
+	// Generations is the number of generations run.
+	Generations int
+
+	// Final is the score at the end of the run.
+	Final float64
+}
+
+// A Result summarizes the runs of a Runner.
+type Result struct {
+	Runs []*RunResult
+
+	// SuccessRate is the fraction of successful runs.
+	SuccessRate float64
+
+	// MeanGenerations is the mean number of generations
+	// needed to reach the target, over successful runs.
+	// It is NaN if no run succeeded.
+	MeanGenerations float64
+
+	// MeanFinal and StdFinal are the mean and standard
+	// deviation of the final scores.
+	MeanFinal float64
+	StdFinal  float64
+}
+
+// String produces a one-line summary.
+func (r *Result) String() string {
+	return fmt.Sprintf("success=%.2f generations=%.1f final=%f (std %f)",
+		r.SuccessRate, r.MeanGenerations, r.MeanFinal, r.StdFinal)
+}
+
+func (r *Result) summarize() {
+	var successes, gens, sum, sqSum float64
+	for _, run := range r.Runs {
+		if run.Success {
+			successes++
+			gens += float64(run.Generations)
+		}
+		sum += run.Final
+		sqSum += run.Final * run.Final
+	}
+	n := float64(len(r.Runs))
+	r.SuccessRate = successes / n
+	r.MeanGenerations = gens / successes
+	if successes == 0 {
+		r.MeanGenerations = math.NaN()
+	}
+	r.MeanFinal = sum / n
+	r.StdFinal = math.Sqrt(math.Max(0, sqSum/n-r.MeanFinal*r.MeanFinal))
+}
@@ -0,0 +1,208 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/leea"
+)
+
+// A Task is a small synthetic supervised learning
+// problem, solved by a network with one hidden layer.
+//
+// For classification tasks, the Score of a Trainer is the
+// accuracy of its best entity on all of the samples.
+// For regression tasks, it is the negative mean squared
+// error.
+type Task struct {
+	Name    string
+	Samples anyff.SliceSampleList
+
+	InSize  int
+	OutSize int
+
+	// Hidden is the number of hidden units.
+	Hidden int
+
+	// Classification indicates that the outputs are
+	// one-hot class labels.
+	Classification bool
+
+	// BatchSize is the number of samples per mini-batch.
+	// If 0, all of the samples are used.
+	BatchSize int
+}
+
+// XOR creates the exclusive-or classification task.
+func XOR() *Task {
+	var samples anyff.SliceSampleList
+	for _, in := range [][2]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		samples = append(samples, classSample(in[:], int(in[0])^int(in[1]), 2))
+	}
+	return &Task{
+		Name:           "xor",
+		Samples:        samples,
+		InSize:         2,
+		OutSize:        2,
+		Hidden:         4,
+		Classification: true,
+	}
+}
+
+// Parity creates the task of computing the parity of a
+// binary string, with one sample for every string.
+func Parity(bits int) *Task {
+	var samples anyff.SliceSampleList
+	for i := 0; i < 1<<uint(bits); i++ {
+		in := make([]float64, bits)
+		var parity int
+		for j := range in {
+			if i&(1<<uint(j)) != 0 {
+				in[j] = 1
+				parity ^= 1
+			}
+		}
+		samples = append(samples, classSample(in, parity, 2))
+	}
+	return &Task{
+		Name:           "parity",
+		Samples:        samples,
+		InSize:         bits,
+		OutSize:        2,
+		Hidden:         2 * bits,
+		Classification: true,
+	}
+}
+
+// Spirals creates the two-spirals classification task,
+// with the given number of points per spiral.
+func Spirals(points int) *Task {
+	var samples anyff.SliceSampleList
+	for i := 0; i < points; i++ {
+		angle := float64(i) * math.Pi / 16
+		radius := 6.5 * float64(104-i) / 104
+		x, y := radius*math.Sin(angle)/6.5, radius*math.Cos(angle)/6.5
+		samples = append(samples, classSample([]float64{x, y}, 0, 2))
+		samples = append(samples, classSample([]float64{-x, -y}, 1, 2))
+	}
+	return &Task{
+		Name:           "spirals",
+		Samples:        samples,
+		InSize:         2,
+		OutSize:        2,
+		Hidden:         16,
+		Classification: true,
+	}
+}
+
+// Regression creates the task of fitting a sine wave with
+// the given number of evenly spaced samples.
+func Regression(points int) *Task {
+	c := anyvec64.DefaultCreator{}
+	var samples anyff.SliceSampleList
+	for i := 0; i < points; i++ {
+		x := -math.Pi + 2*math.Pi*float64(i)/float64(points-1)
+		samples = append(samples, &anyff.Sample{
+			Input:  c.MakeVectorData([]float64{x}),
+			Output: c.MakeVectorData([]float64{math.Sin(x)}),
+		})
+	}
+	return &Task{
+		Name:    "regression",
+		Samples: samples,
+		InSize:  1,
+		OutSize: 1,
+		Hidden:  8,
+	}
+}
+
+// Configure sets up a Trainer to train networks on the
+// task.
+func (t *Task) Configure(tr *leea.Trainer, popSize int, r *rand.Rand) {
+	batchSize := t.BatchSize
+	if batchSize == 0 {
+		batchSize = len(t.Samples)
+	}
+	tr.Evaluator = &leea.NegCost{Cost: t.cost()}
+	tr.Samples = &leea.CycleSampleSource{
+		Samples:   append(anyff.SliceSampleList{}, t.Samples...),
+		BatchSize: batchSize,
+	}
+	tr.Fetcher = &anyff.Trainer{}
+	tr.Population = nil
+
+	c := anyvec64.DefaultCreator{}
+	for i := 0; i < popSize; i++ {
+		net := anynet.Net{
+			newFC(c, t.InSize, t.Hidden, r),
+			anynet.Tanh,
+			newFC(c, t.Hidden, t.OutSize, r),
+		}
+		if t.Classification {
+			net = append(net, anynet.LogSoftmax)
+		}
+		tr.Population = append(tr.Population, &leea.FitEntity{
+			Entity: &leea.NetEntity{Parameterizer: net},
+		})
+	}
+}
+
+// Score evaluates the best entity on all of the samples.
+func (t *Task) Score(tr *leea.Trainer) float64 {
+	var inputs, outputs []anyvec.Vector
+	for _, s := range t.Samples {
+		inputs = append(inputs, s.Input)
+		outputs = append(outputs, s.Output)
+	}
+	c := anyvec64.DefaultCreator{}
+	batch := &anyff.Batch{
+		Inputs:  anydiff.NewConst(c.Concat(inputs...)),
+		Outputs: anydiff.NewConst(c.Concat(outputs...)),
+		Num:     len(t.Samples),
+	}
+	best := tr.BestEntity().Entity
+	if t.Classification {
+		return (&leea.Accuracy{}).Evaluate(best, batch)
+	}
+	return (&leea.NegCost{Cost: t.cost()}).Evaluate(best, batch)
+}
+
+// Target is 1 for classification tasks, i.e. perfect
+// accuracy, and -0.01 for regression tasks.
+func (t *Task) Target() float64 {
+	if t.Classification {
+		return 1
+	}
+	return -0.01
+}
+
+func (t *Task) cost() anynet.Cost {
+	if t.Classification {
+		return anynet.DotCost{}
+	}
+	return anynet.MSE{}
+}
+
+func classSample(in []float64, class, numClasses int) *anyff.Sample {
+	c := anyvec64.DefaultCreator{}
+	out := make([]float64, numClasses)
+	out[class] = 1
+	return &anyff.Sample{
+		Input:  c.MakeVectorData(append([]float64{}, in...)),
+		Output: c.MakeVectorData(out),
+	}
+}
+
+// newFC is like anynet.NewFC, but it draws the weights
+// from r.
+func newFC(c anyvec.Creator, in, out int, r *rand.Rand) *anynet.FC {
+	res := anynet.NewFCZero(c, in, out)
+	anyvec.Rand(res.Weights.Vector, anyvec.Normal, r)
+	res.Weights.Vector.Scale(c.MakeNumeric(1 / math.Sqrt(float64(in))))
+	return res
+}
@@ -0,0 +1,165 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anyvec"
+)
+
+// An ArithmeticCrosser performs cross-over by setting the
+// parameters of the destination to a weighted average of
+// both entities' parameters, giving the destination a
+// weight of keep.
+//
+// Both entities must implement anynet.Parameterizer.
+type ArithmeticCrosser struct {
+	// Random indicates that every parameter should get its
+	// own random weight.
+	// Weights are drawn uniformly from the widest range
+	// around keep that stays within [0, 1], so that their
+	// mean is still keep.
+	Random bool
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (a *ArithmeticCrosser) SetRand(r *rand.Rand) {
+	a.rng.Rand = r
+}
+
+// Cross performs cross-over.
+func (a *ArithmeticCrosser) Cross(dest, source Entity, keep float64) {
+	defer touchEntity(dest)
+	if !a.Random {
+		interpolate(dest, source, func(c anyvec.Creator, n int) anyvec.Vector {
+			res := c.MakeVector(n)
+			res.AddScalar(c.MakeNumeric(keep))
+			return res
+		})
+		return
+	}
+	interpolate(dest, source, func(c anyvec.Creator, n int) anyvec.Vector {
+		return uniformRatios(c, n, keep, blendRadius(keep), a.rng)
+	})
+}
+
+// A BLXCrosser performs blend cross-over (BLX-alpha).
+// Every parameter of the destination is drawn uniformly
+// from the interval spanned by both parents, extended on
+// both sides by Alpha times the distance between them.
+//
+// The interval is centered at the weighted average for
+// keep and shrinks as keep approaches 0 or 1, so keep=0.5
+// gives standard BLX-alpha while keep=1 leaves the
+// destination unchanged.
+//
+// Both entities must implement anynet.Parameterizer.
+type BLXCrosser struct {
+	// Alpha is the fraction by which the interval is
+	// extended.
+	// If 0, no extension is used (BLX-0.0).
+	Alpha float64
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (b *BLXCrosser) SetRand(r *rand.Rand) {
+	b.rng.Rand = r
+}
+
+// Cross performs cross-over.
+func (b *BLXCrosser) Cross(dest, source Entity, keep float64) {
+	defer touchEntity(dest)
+	radius := blendRadius(keep) * (1 + 2*b.Alpha)
+	interpolate(dest, source, func(c anyvec.Creator, n int) anyvec.Vector {
+		return uniformRatios(c, n, keep, radius, b.rng)
+	})
+}
+
+// An SBXCrosser performs simulated binary cross-over.
+// Every parameter of the destination is set to one of
+// the two children that SBX would produce, chosen at
+// random.
+//
+// As with BLXCrosser, the spread of the children is
+// centered at the weighted average for keep and shrinks
+// as keep approaches 0 or 1, so keep=0.5 gives standard
+// SBX.
+//
+// Both entities must implement anynet.Parameterizer.
+type SBXCrosser struct {
+	// Eta is the distribution index.
+	// Larger values keep children closer to their parents.
+	// If 0, a default of 2 is used.
+	Eta float64
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (s *SBXCrosser) SetRand(r *rand.Rand) {
+	s.rng.Rand = r
+}
+
+// Cross performs cross-over.
+func (s *SBXCrosser) Cross(dest, source Entity, keep float64) {
+	defer touchEntity(dest)
+	eta := s.Eta
+	if eta == 0 {
+		eta = 2
+	}
+	radius := blendRadius(keep)
+	interpolate(dest, source, func(c anyvec.Creator, n int) anyvec.Vector {
+		ratios := make([]float64, n)
+		for i := range ratios {
+			u := s.rng.Float64()
+			var beta float64
+			if u <= 0.5 {
+				beta = math.Pow(2*u, 1/(eta+1))
+			} else {
+				beta = math.Pow(1/(2*(1-u)), 1/(eta+1))
+			}
+			if s.rng.Intn(2) == 0 {
+				beta = -beta
+			}
+			ratios[i] = keep + radius*beta
+		}
+		return c.MakeVectorData(c.MakeNumericList(ratios))
+	})
+}
+
+// interpolate sets every parameter d of dest to
+// s + r*(d-s), where s is the corresponding parameter of
+// source and r is an interpolation ratio.
+// A ratio of 1 keeps d, while a ratio of 0 takes s.
+func interpolate(dest, source Entity, ratios func(c anyvec.Creator, n int) anyvec.Vector) {
+	srcParams := entityParameters(source)
+	for i, p := range entityParameters(dest) {
+		d := p.Vector
+		s := srcParams[i].Vector
+		diff := d.Copy()
+		diff.Sub(s)
+		diff.Mul(ratios(d.Creator(), d.Len()))
+		diff.Add(s)
+		d.Set(diff)
+	}
+}
+
+// uniformRatios draws ratios uniformly from the interval
+// [center-radius, center+radius].
+func uniformRatios(c anyvec.Creator, n int, center, radius float64, r rng) anyvec.Vector {
+	res := c.MakeVector(n)
+	anyvec.Rand(res, anyvec.Uniform, r.Rand)
+	res.Scale(c.MakeNumeric(2 * radius))
+	res.AddScalar(c.MakeNumeric(center - radius))
+	return res
+}
+
+// blendRadius computes the largest distance from keep
+// which stays within [0, 1].
+func blendRadius(keep float64) float64 {
+	return math.Min(keep, 1-keep)
+}
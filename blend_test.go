@@ -0,0 +1,68 @@
+package leea
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBlendCrossers(t *testing.T) {
+	crossers := map[string]Crosser{
+		"arithmetic": &ArithmeticCrosser{Random: true},
+		"blx":        &BLXCrosser{Alpha: 0.5},
+		"sbx":        &SBXCrosser{},
+	}
+	for name, crosser := range crossers {
+		for _, keep := range []float64{0, 1} {
+			dest := NewVectorEntity([]float64{1, 2, 3})
+			crosser.Cross(dest, NewVectorEntity([]float64{3, 2, 1}), keep)
+			expected := []float64{1, 2, 3}
+			if keep == 0 {
+				expected = []float64{3, 2, 1}
+			}
+			for i, x := range dest.Floats() {
+				if math.Abs(x-expected[i]) > 1e-8 {
+					t.Errorf("%s keep %f: expected %v but got %v", name, keep,
+						expected, dest.Floats())
+					break
+				}
+			}
+		}
+
+		// With keep=0.75, the destination should move a
+		// quarter of the way to the source on average.
+		data := make([]float64, 10000)
+		dest := NewVectorEntity(data)
+		for i := range data {
+			data[i] = 1
+		}
+		crosser.Cross(dest, NewVectorEntity(data), 0.75)
+		var mean float64
+		for _, x := range dest.Floats() {
+			mean += x / float64(len(data))
+		}
+		if math.Abs(mean-0.25) > 0.03 {
+			t.Errorf("%s: expected mean 0.25 but got %f", name, mean)
+		}
+	}
+}
+
+func TestBLXCrosserRange(t *testing.T) {
+	dest := NewVectorEntity(make([]float64, 1000))
+	source := make([]float64, 1000)
+	for i := range source {
+		source[i] = 1
+	}
+	(&BLXCrosser{Alpha: 0.5}).Cross(dest, NewVectorEntity(source), 0.5)
+	var outside int
+	for _, x := range dest.Floats() {
+		if x < -0.5 || x > 1.5 {
+			t.Fatalf("value out of range: %f", x)
+		}
+		if x < 0 || x > 1 {
+			outside++
+		}
+	}
+	if outside == 0 {
+		t.Error("no values beyond the parents")
+	}
+}
@@ -0,0 +1,251 @@
+package leea
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// DefaultCacheSize is the default number of scores which
+// a CachedEvaluator remembers.
+const DefaultCacheSize = 1 << 14
+
+// DefaultCacheBatches is the default number of batches
+// for which a CachedEvaluator remembers scores.
+const DefaultCacheBatches = 64
+
+// A CachedEvaluator wraps an Evaluator and skips
+// evaluations which have already been performed.
+//
+// Scores are keyed by the version of the entity (see
+// Versioned) and the identity of the batch, so the cache
+// only helps when the same batch object is evaluated more
+// than once, as with BatchWindow or a Validator which
+// does not Resample.
+// Entities which are not Versioned and batches which are
+// nil or not comparable are never cached.
+//
+// If the wrapped Evaluator is a PopulationEvaluator or a
+// SampleEvaluator, so is the CachedEvaluator.
+// EvaluateAll only evaluates the entities which are not
+// in the cache.
+// Per-sample fitnesses are never cached, but the scores
+// computed alongside them are.
+//
+// It is safe to call Evaluate concurrently.
+type CachedEvaluator struct {
+	Evaluator Evaluator
+
+	// Size is the maximum number of cached scores.
+	// The oldest scores are evicted first.
+	// If 0, DefaultCacheSize is used.
+	Size int
+
+	// Batches is the maximum number of batches with cached
+	// scores.
+	// When a new batch would exceed it, every score for
+	// the oldest batch is evicted, so that the cache does
+	// not keep old batches in memory.
+	// If 0, DefaultCacheBatches is used.
+	Batches int
+
+	// Hits and Misses count cache lookups.
+	Hits   int
+	Misses int
+
+	lock    sync.Mutex
+	scores  map[anysgd.Batch]map[uint64]float64
+	batches []anysgd.Batch
+	order   []cacheKey
+	count   int
+}
+
+type cacheKey struct {
+	Version uint64
+	Batch   anysgd.Batch
+}
+
+// Evaluate returns a cached score if possible, or else
+// evaluates the entity and caches the result.
+func (c *CachedEvaluator) Evaluate(e Entity, b anysgd.Batch) float64 {
+	res, err := c.EvaluateErr(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr is like Evaluate, but it returns errors
+// from the wrapped Evaluator if it is a
+// FallibleEvaluator.
+func (c *CachedEvaluator) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	key, ok := c.key(e, b)
+	if !ok {
+		return evaluate(c.Evaluator, e, b)
+	}
+	if score, ok := c.lookup(key); ok {
+		return score, nil
+	}
+	score, err := evaluate(c.Evaluator, e, b)
+	if err != nil {
+		return 0, err
+	}
+	c.store(key, score)
+	return score, nil
+}
+
+// CanEvaluateAll returns true if the wrapped Evaluator is
+// a PopulationEvaluator which supports the entities and
+// the batch.
+func (c *CachedEvaluator) CanEvaluateAll(e []Entity, b anysgd.Batch) bool {
+	pe, ok := c.Evaluator.(PopulationEvaluator)
+	return ok && pe.CanEvaluateAll(e, b)
+}
+
+// EvaluateAll evaluates the entities which are not in the
+// cache with the wrapped PopulationEvaluator.
+func (c *CachedEvaluator) EvaluateAll(e []Entity, b anysgd.Batch) []float64 {
+	res := make([]float64, len(e))
+	keys := make([]cacheKey, len(e))
+	cacheable := make([]bool, len(e))
+	var missing []Entity
+	var missingIdxs []int
+	for i, entity := range e {
+		keys[i], cacheable[i] = c.key(entity, b)
+		if cacheable[i] {
+			if score, ok := c.lookup(keys[i]); ok {
+				res[i] = score
+				continue
+			}
+		}
+		missing = append(missing, entity)
+		missingIdxs = append(missingIdxs, i)
+	}
+	if len(missing) == 0 {
+		return res
+	}
+	scores := c.Evaluator.(PopulationEvaluator).EvaluateAll(missing, b)
+	for i, idx := range missingIdxs {
+		res[idx] = scores[i]
+		if cacheable[idx] {
+			c.store(keys[idx], scores[i])
+		}
+	}
+	return res
+}
+
+// SampleFitnesses calls the wrapped Evaluator, which must
+// be a SampleEvaluator.
+func (c *CachedEvaluator) SampleFitnesses(e Entity, b anysgd.Batch) []float64 {
+	_, res, err := c.EvaluateSamples(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateSamples computes the score and per-sample
+// fitnesses with the wrapped Evaluator, which must be a
+// SampleEvaluator, and caches the score.
+func (c *CachedEvaluator) EvaluateSamples(e Entity, b anysgd.Batch) (float64, []float64, error) {
+	score, samples, err := evaluateSamples(c.Evaluator, e, b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if key, ok := c.key(e, b); ok {
+		c.store(key, score)
+	}
+	return score, samples, nil
+}
+
+// Clear removes all of the cached scores.
+func (c *CachedEvaluator) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.scores = nil
+	c.batches = nil
+	c.order = nil
+	c.count = 0
+}
+
+func (c *CachedEvaluator) key(e Entity, b anysgd.Batch) (cacheKey, bool) {
+	v, ok := e.(Versioned)
+	if !ok || b == nil || !reflect.TypeOf(b).Comparable() {
+		return cacheKey{}, false
+	}
+	return cacheKey{Version: v.Version(), Batch: b}, true
+}
+
+func (c *CachedEvaluator) lookup(key cacheKey) (float64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	score, ok := c.scores[key.Batch][key.Version]
+	if ok {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+	return score, ok
+}
+
+func (c *CachedEvaluator) store(key cacheKey, score float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.scores[key.Batch][key.Version]; ok {
+		return
+	}
+
+	size := c.Size
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+	maxBatches := c.Batches
+	if maxBatches == 0 {
+		maxBatches = DefaultCacheBatches
+	}
+	if c.scores == nil {
+		c.scores = map[anysgd.Batch]map[uint64]float64{}
+	}
+	if _, ok := c.scores[key.Batch]; !ok {
+		for len(c.batches) >= maxBatches {
+			c.removeBatch(c.batches[0])
+		}
+		c.scores[key.Batch] = map[uint64]float64{}
+		c.batches = append(c.batches, key.Batch)
+	}
+	for c.count >= size {
+		old := c.order[0]
+		c.order[0] = cacheKey{}
+		c.order = c.order[1:]
+		delete(c.scores[old.Batch], old.Version)
+		c.count--
+		if len(c.scores[old.Batch]) == 0 && old.Batch != key.Batch {
+			c.removeBatch(old.Batch)
+		}
+	}
+	c.scores[key.Batch][key.Version] = score
+	c.order = append(c.order, key)
+	c.count++
+}
+
+// removeBatch evicts every score for a batch and drops all
+// references to the batch.
+func (c *CachedEvaluator) removeBatch(b anysgd.Batch) {
+	c.count -= len(c.scores[b])
+	delete(c.scores, b)
+	var batches []anysgd.Batch
+	for _, batch := range c.batches {
+		if batch != b {
+			batches = append(batches, batch)
+		}
+	}
+	c.batches = batches
+	var order []cacheKey
+	for _, key := range c.order {
+		if key.Batch != b {
+			order = append(order, key)
+		}
+	}
+	c.order = order
+}
@@ -0,0 +1,206 @@
+package leea
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+type countingEvaluator struct {
+	Count int
+}
+
+func (c *countingEvaluator) Evaluate(e Entity, b anysgd.Batch) float64 {
+	c.Count++
+	return e.(*NetEntity).Parameters()[0].Vector.Data().([]float64)[0]
+}
+
+func TestCachedEvaluator(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	e1 := &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)}
+	e2 := &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)}
+	b1, b2 := &anyff.Batch{}, &anyff.Batch{}
+
+	inner := &countingEvaluator{}
+	cached := &CachedEvaluator{Evaluator: inner}
+
+	expectCount := func(ctx string, n int) {
+		if inner.Count != n {
+			t.Errorf("%s: expected %d evaluations but got %d", ctx, n, inner.Count)
+		}
+	}
+
+	cached.Evaluate(e1, b1)
+	cached.Evaluate(e1, b1)
+	expectCount("repeat", 1)
+	cached.Evaluate(e2, b1)
+	cached.Evaluate(e1, b2)
+	expectCount("new keys", 3)
+
+	e2.Set(e1)
+	if cached.Evaluate(e2, b1) != cached.Evaluate(e1, b1) {
+		t.Error("score mismatch after Set")
+	}
+	expectCount("after Set", 3)
+
+	e1.Decay(0.5)
+	cached.Evaluate(e1, b1)
+	expectCount("after Decay", 4)
+
+	(&Trainer{Mutator: &AddMutator{Stddev: &ExpSchedule{Baseline: 1}}}).mutateAll(
+		[]*FitEntity{{Entity: e2}},
+	)
+	cached.Evaluate(e2, b1)
+	expectCount("after mutation", 5)
+
+	if cached.Hits != 3 || cached.Misses != 5 {
+		t.Errorf("unexpected hits/misses: %d/%d", cached.Hits, cached.Misses)
+	}
+
+	cached.Size = 1
+	cached.Clear()
+	cached.Evaluate(e1, b1)
+	cached.Evaluate(e1, b2)
+	cached.Evaluate(e1, b1)
+	expectCount("eviction", 8)
+}
+
+func TestCachedEvaluatorDirectChanges(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	inner := &countingEvaluator{}
+	cached := &CachedEvaluator{Evaluator: inner}
+	b := &anyff.Batch{}
+
+	e := &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)}
+	cached.Evaluate(e, b)
+	(&AddMutator{Stddev: &ExpSchedule{Baseline: 1}}).Mutate(0, e, rand.NewSource(1))
+	cached.Evaluate(e, b)
+	if inner.Count != 2 {
+		t.Errorf("mutation: expected 2 evaluations but got %d", inner.Count)
+	}
+
+	crossers := []Crosser{
+		&UniformCrosser{},
+		&ArithmeticCrosser{},
+		&BLXCrosser{},
+		&SBXCrosser{},
+		&NeuronalCrosser{},
+		&AlignedCrosser{},
+		&LayerCrosser{},
+		&CellCrosser{},
+	}
+	for _, crosser := range crossers {
+		source := &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)}
+		cached.Evaluate(e, b)
+		count := inner.Count
+		crosser.Cross(e, source, 0.5)
+		cached.Evaluate(e, b)
+		if inner.Count != count+1 {
+			t.Errorf("%T: cross-over did not change the version", crosser)
+		}
+	}
+}
+
+type countingPopEvaluator struct {
+	countingEvaluator
+	Sizes []int
+}
+
+func (c *countingPopEvaluator) CanEvaluateAll(e []Entity, b anysgd.Batch) bool {
+	return true
+}
+
+func (c *countingPopEvaluator) EvaluateAll(e []Entity, b anysgd.Batch) []float64 {
+	c.Sizes = append(c.Sizes, len(e))
+	var res []float64
+	for _, entity := range e {
+		res = append(res, c.Evaluate(entity, b))
+	}
+	return res
+}
+
+func TestCachedEvaluatorPopulation(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	inner := &countingPopEvaluator{}
+	cached := &CachedEvaluator{Evaluator: inner}
+	var entities []Entity
+	for i := 0; i < 3; i++ {
+		entities = append(entities, &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)})
+	}
+	b := &anyff.Batch{}
+
+	if !cached.CanEvaluateAll(entities, b) {
+		t.Fatal("should forward PopulationEvaluator")
+	}
+	cached.Evaluate(entities[1], b)
+	scores := cached.EvaluateAll(entities, b)
+	for i, e := range entities {
+		if expected := inner.Evaluate(e, b); scores[i] != expected {
+			t.Errorf("entity %d: expected %f but got %f", i, expected, scores[i])
+		}
+	}
+	cached.EvaluateAll(entities, b)
+	if len(inner.Sizes) != 1 || inner.Sizes[0] != 2 {
+		t.Errorf("expected one call on the 2 uncached entities but got %v", inner.Sizes)
+	}
+
+	if (&CachedEvaluator{Evaluator: &countingEvaluator{}}).CanEvaluateAll(entities, b) {
+		t.Error("should not evaluate all without a PopulationEvaluator")
+	}
+}
+
+func TestCachedEvaluatorSamples(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	entity := &NetEntity{Parameterizer: anynet.Net{anynet.NewFC(c, 2, 2), anynet.LogSoftmax}}
+	batch := &anyff.Batch{
+		Inputs:  anydiff.NewConst(c.MakeVectorData([]float64{1, 0, 0, 1})),
+		Outputs: anydiff.NewConst(c.MakeVectorData([]float64{1, 0, 1, 0})),
+		Num:     2,
+	}
+	cached := &CachedEvaluator{Evaluator: &Accuracy{}}
+	score, fits, err := cached.EvaluateSamples(entity, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fits) != 2 || score != (fits[0]+fits[1])/2 {
+		t.Errorf("unexpected score %f with fitnesses %v", score, fits)
+	}
+	cached.Evaluate(entity, batch)
+	if cached.Hits != 1 {
+		t.Error("score from EvaluateSamples should be cached")
+	}
+
+	_, _, err = (&CachedEvaluator{Evaluator: &countingEvaluator{}}).EvaluateSamples(entity, batch)
+	if err == nil {
+		t.Error("expected an error without a SampleEvaluator")
+	}
+}
+
+func TestCachedEvaluatorBatches(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	e := &NetEntity{Parameterizer: anynet.NewFC(c, 3, 2)}
+	inner := &countingEvaluator{}
+	cached := &CachedEvaluator{Evaluator: inner, Batches: 2}
+	b1, b2, b3 := &anyff.Batch{}, &anyff.Batch{}, &anyff.Batch{}
+	cached.Evaluate(e, b1)
+	cached.Evaluate(e, b2)
+	cached.Evaluate(e, b3)
+	if len(cached.scores) != 2 || len(cached.batches) != 2 || len(cached.order) != 2 {
+		t.Fatal("oldest batch was not evicted")
+	}
+	for _, key := range cached.order {
+		if key.Batch == b1 {
+			t.Fatal("evicted batch is still referenced")
+		}
+	}
+	cached.Evaluate(e, b3)
+	cached.Evaluate(e, b1)
+	if inner.Count != 4 {
+		t.Errorf("expected 4 evaluations but got %d", inner.Count)
+	}
+}
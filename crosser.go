@@ -1,9 +1,13 @@
 package leea
 
 import (
+	"fmt"
+	"math/rand"
+
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyconv"
 	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
 )
 
@@ -16,6 +20,33 @@ type Crosser interface {
 	Cross(dest, source Entity, keep float64)
 }
 
+// A FallibleCrosser is a Crosser which can report
+// unsupported entities as errors instead of panicking.
+type FallibleCrosser interface {
+	Crosser
+
+	CrossErr(dest, source Entity, keep float64) error
+}
+
+// A BatchCrosser is a Crosser which uses the current
+// mini-batch, for example to compare activations.
+// The Trainer calls SetBatch before every round of
+// cross-over.
+type BatchCrosser interface {
+	Crosser
+
+	SetBatch(b anysgd.Batch)
+}
+
+// cross uses CrossErr if possible, or else Cross.
+func cross(c Crosser, dest, source Entity, keep float64) error {
+	if fc, ok := c.(FallibleCrosser); ok {
+		return fc.CrossErr(dest, source, keep)
+	}
+	c.Cross(dest, source, keep)
+	return nil
+}
+
 // A NeuronalCrosser performs cross-over on entire neurons
 // at a time in a neural network, using a default crosser
 // when it does not recognize the type of a learner.
@@ -23,67 +54,133 @@ type Crosser interface {
 // Specifically, a NeuronalCrosser knows how to deal with
 // the following types:
 //
-//     *anynet.FC
-//     anynet.Net
-//     anyrnn.Stack
-//     *anyrnn.LayerBlock
-//     *anyrnn.Vanilla
-//     *anyconv.Conv
+//	*anynet.FC
+//	*anynet.Affine
+//	anynet.Net
+//	anyrnn.Stack
+//	*anyrnn.LayerBlock
+//	*anyrnn.Vanilla
+//	*anyrnn.LSTM
+//	*anyconv.Conv
+//	*anyconv.BatchNorm
 //
 // For the above types, the crosser can unwrap the type
 // and apply cross-over to its constituent parts.
-type NeuronalCrosser struct{}
+// Containers are unwrapped as described by walkLayers.
+// The gates of an LSTM are crossed together, so that
+// every unit keeps a consistent set of gates.
+type NeuronalCrosser struct {
+	// Default is used for parameterized layers of other
+	// types.
+	// If nil, such layers cause an error.
+	Default Crosser
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (n *NeuronalCrosser) SetRand(r *rand.Rand) {
+	n.rng.Rand = r
+	setRand(n.Default, r)
+}
 
 // Cross performs cross-over.
 // Both entities must be *NetEntity objects.
+// It panics if the entities are unsupported.
 func (n *NeuronalCrosser) Cross(dest, source Entity, keep float64) {
-	n.cross(dest.(*NetEntity).Parameterizer, source.(*NetEntity).Parameterizer, keep)
+	if err := n.CrossErr(dest, source, keep); err != nil {
+		panic(err)
+	}
+}
+
+// CrossErr performs cross-over.
+// Both entities must be *NetEntity objects.
+func (n *NeuronalCrosser) CrossErr(dest, source Entity, keep float64) error {
+	defer touchEntity(dest)
+	destNet, ok1 := dest.(*NetEntity)
+	sourceNet, ok2 := source.(*NetEntity)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("neuronal cross-over: unsupported entities: %T and %T",
+			dest, source)
+	}
+	return n.cross(destNet.Parameterizer, sourceNet.Parameterizer, keep)
+}
+
+func (n *NeuronalCrosser) cross(dest, source anynet.Parameterizer, keep float64) error {
+	return walkLayers(dest, source, func(dest, source anynet.Parameterizer) error {
+		return n.crossLayer(dest, source, keep)
+	})
 }
 
-func (n *NeuronalCrosser) cross(dest, source anynet.Parameterizer, keep float64) {
+func (n *NeuronalCrosser) crossLayer(dest, source anynet.Parameterizer,
+	keep float64) error {
 	switch dest := dest.(type) {
-	case anynet.Net:
-		source := source.(anynet.Net)
-		for i, layer := range dest {
-			if p, ok := layer.(anynet.Parameterizer); ok {
-				sourceLayer := source[i].(anynet.Parameterizer)
-				n.cross(p, sourceLayer, keep)
-			}
-		}
 	case *anynet.FC:
 		source := source.(*anynet.FC)
 		n.crossRows(keep, dest.OutCount, dest.Weights.Vector, source.Weights.Vector,
 			dest.Biases.Vector, source.Biases.Vector)
+	case *anynet.Affine:
+		source := source.(*anynet.Affine)
+		n.crossScalers(keep, dest.Scalers.Vector, source.Scalers.Vector,
+			dest.Biases.Vector, source.Biases.Vector)
 	case *anyconv.Conv:
 		source := source.(*anyconv.Conv)
 		count := dest.Biases.Vector.Len()
 		n.crossRows(keep, count, dest.Filters.Vector, source.Filters.Vector,
 			dest.Biases.Vector, source.Biases.Vector)
-	case anyrnn.Stack:
-		source := source.(anyrnn.Stack)
-		for i, x := range dest {
-			if p, ok := x.(anynet.Parameterizer); ok {
-				n.cross(p, source[i].(anynet.Parameterizer), keep)
-			}
-		}
+	case *anyconv.BatchNorm:
+		source := source.(*anyconv.BatchNorm)
+		n.crossScalers(keep, dest.Scalers.Vector, source.Scalers.Vector,
+			dest.Biases.Vector, source.Biases.Vector)
 	case *anyrnn.Vanilla:
 		source := source.(*anyrnn.Vanilla)
 		n.crossRows(keep, dest.OutCount, dest.InputWeights.Vector,
 			source.InputWeights.Vector, dest.StateWeights.Vector,
 			source.StateWeights.Vector, dest.Biases.Vector,
 			source.Biases.Vector)
-	case *anyrnn.LayerBlock:
-		if p, ok := dest.Layer.(anynet.Parameterizer); ok {
-			pSource := source.(*anyrnn.LayerBlock).Layer.(anynet.Parameterizer)
-			n.cross(p, pSource, keep)
+	case *anyrnn.LSTM:
+		source := source.(*anyrnn.LSTM)
+		var mats []anyvec.Vector
+		destGates := []*anyrnn.LSTMGate{dest.InValue, dest.In, dest.Remember, dest.Output}
+		sourceGates := []*anyrnn.LSTMGate{source.InValue, source.In, source.Remember,
+			source.Output}
+		for i, gate := range destGates {
+			sourceParams := sourceGates[i].Parameters()
+			for j, p := range gate.Parameters() {
+				mats = append(mats, p.Vector, sourceParams[j].Vector)
+			}
 		}
+		mats = append(mats, dest.InitLastOut.Vector, source.InitLastOut.Vector,
+			dest.InitInternal.Vector, source.InitInternal.Vector)
+		n.crossRows(keep, dest.Output.Biases.Vector.Len(), mats...)
+	default:
+		if n.Default == nil {
+			return fmt.Errorf("neuronal cross-over: unsupported layer: %T", dest)
+		}
+		return cross(n.Default, &NetEntity{Parameterizer: dest},
+			&NetEntity{Parameterizer: source}, keep)
+	}
+	return nil
+}
+
+// crossScalers performs cross-over on per-channel scalers
+// and biases, which are aligned unless their lengths
+// differ.
+func (n *NeuronalCrosser) crossScalers(keep float64, destScalers, sourceScalers,
+	destBiases, sourceBiases anyvec.Vector) {
+	if destScalers.Len() == destBiases.Len() {
+		n.crossRows(keep, destScalers.Len(), destScalers, sourceScalers,
+			destBiases, sourceBiases)
+	} else {
+		n.crossRows(keep, destScalers.Len(), destScalers, sourceScalers)
+		n.crossRows(keep, destBiases.Len(), destBiases, sourceBiases)
 	}
 }
 
 func (n *NeuronalCrosser) crossRows(keep float64, numRows int, mats ...anyvec.Vector) {
 	keepDest := mats[0].Creator().MakeVector(numRows)
-	anyvec.Rand(keepDest, anyvec.Uniform, nil)
-	anyvec.GreaterThan(keepDest, keepDest.Creator().MakeNumeric(keep))
+	anyvec.Rand(keepDest, anyvec.Uniform, n.rng.Rand)
+	anyvec.GreaterThan(keepDest, keepDest.Creator().MakeNumeric(1-keep))
 	takeSrc := keepDest.Copy()
 	anyvec.Complement(takeSrc)
 
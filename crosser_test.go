@@ -0,0 +1,106 @@
+package leea
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestNeuronalCrosserLSTM(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	dest := &NetEntity{Parameterizer: anyrnn.NewLSTM(c, 3, 10)}
+	source := &NetEntity{Parameterizer: anyrnn.NewLSTM(c, 3, 10)}
+	fillParams(dest, 1)
+	fillParams(source, 2)
+	if err := (&NeuronalCrosser{}).CrossErr(dest, source, 0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every unit should take all of its gate parameters
+	// from the same parent.
+	lstm := dest.Parameterizer.(*anyrnn.LSTM)
+	units := c.Float64Slice(lstm.InitInternal.Vector.Data())
+	for _, p := range lstm.Parameters() {
+		data := c.Float64Slice(p.Vector.Data())
+		rowSize := len(data) / len(units)
+		for i, x := range data {
+			if x != units[i/rowSize] {
+				t.Fatalf("unit %d is inconsistent", i/rowSize)
+			}
+		}
+	}
+}
+
+func TestNeuronalCrosserNormalization(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	newNet := func() anynet.Net {
+		return anynet.Net{
+			anynet.NewFC(c, 3, 4),
+			&anyconv.BatchNorm{
+				InputCount: 4,
+				Scalers:    anydiffVar(c, 4),
+				Biases:     anydiffVar(c, 4),
+			},
+			anynet.NewAffine(c, 1, 0),
+		}
+	}
+	dest := &NetEntity{Parameterizer: newNet()}
+	source := &NetEntity{Parameterizer: newNet()}
+	fillParams(source, 2)
+	if err := (&NeuronalCrosser{}).CrossErr(dest, source, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range dest.Parameters() {
+		for _, x := range c.Float64Slice(p.Vector.Data()) {
+			if x != 2 {
+				t.Fatalf("expected all parameters from source but got %f", x)
+			}
+		}
+	}
+}
+
+func TestNeuronalCrosserDefault(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	newNet := func() anynet.Net {
+		return anynet.Net{&anyconv.Residual{Layer: anynet.NewFC(c, 2, 2)}}
+	}
+	dest := &NetEntity{Parameterizer: newNet()}
+	source := &NetEntity{Parameterizer: newNet()}
+	if err := (&NeuronalCrosser{}).CrossErr(dest, source, 0.5); err == nil {
+		t.Error("expected error for unsupported layer")
+	}
+
+	fillParams(source, 2)
+	crosser := &NeuronalCrosser{Default: &ArithmeticCrosser{}}
+	if err := crosser.CrossErr(dest, source, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range dest.Parameters() {
+		for _, x := range c.Float64Slice(p.Vector.Data()) {
+			if x != 2 {
+				t.Fatalf("expected default crosser to copy source but got %f", x)
+			}
+		}
+	}
+
+	mismatch := &NetEntity{Parameterizer: anynet.Net{anynet.NewFC(c, 2, 2)}}
+	if err := crosser.CrossErr(dest, mismatch, 0.5); err == nil {
+		t.Error("expected error for mismatching layers")
+	}
+}
+
+func fillParams(e *NetEntity, value float64) {
+	for _, p := range e.Parameters() {
+		p.Vector.Scale(p.Vector.Creator().MakeNumeric(0))
+		p.Vector.AddScalar(p.Vector.Creator().MakeNumeric(value))
+	}
+}
+
+func anydiffVar(c anyvec.Creator, size int) *anydiff.Var {
+	return anydiff.NewVar(c.MakeVector(size))
+}
@@ -0,0 +1,176 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// A CurriculumSampleSource produces mini-batches from a
+// pool of eligible samples which starts with the easiest
+// samples and widens as training progresses.
+//
+// The pool can be widened according to a Schedule, or in
+// discrete stages whenever the population reaches a
+// fitness threshold.
+//
+// Like CycleSampleSource, it reorders the sample list,
+// so the batches it returns are only valid until the
+// next call to MiniBatch.
+type CurriculumSampleSource struct {
+	// Samples contains the samples to draw from.
+	Samples anysgd.SampleList
+
+	// Difficulty returns the difficulty of the sample at
+	// the given index in a list.
+	// It is called once per sample, on the first call to
+	// Progress or MiniBatch.
+	Difficulty func(s anysgd.SampleList, idx int) float64
+
+	// BatchSize indicates the number of samples to return
+	// from MiniBatch().
+	// The pool of eligible samples is never smaller than
+	// this.
+	BatchSize int
+
+	// Pacing, if non-nil, determines the fraction of the
+	// samples which are eligible at each generation.
+	Pacing Schedule
+
+	// Stages is used when Pacing is nil.
+	// It contains the ascending fractions of eligible
+	// samples for each stage of the curriculum.
+	Stages []float64
+
+	// Thresholds contains the best running fitness needed
+	// to advance past each stage.
+	// It should have one fewer element than Stages.
+	Thresholds []float64
+
+	// Observer, if non-nil, is called whenever the pool of
+	// eligible samples changes.
+	Observer func(stage int, fraction float64)
+
+	// Stage is the current stage of the curriculum.
+	// When Pacing is used, it counts the number of times
+	// the pool has grown.
+	Stage int
+
+	// Fraction is the current fraction of eligible
+	// samples.
+	Fraction float64
+
+	sorted   bool
+	eligible int
+	curIdx   int
+}
+
+// Progress updates the pool of eligible samples.
+func (c *CurriculumSampleSource) Progress(t *Trainer) {
+	c.sortSamples()
+
+	stage, fraction := c.Stage, c.Fraction
+	if c.Pacing != nil {
+		fraction = math.Max(0, math.Min(1, c.Pacing.ValueAtTime(t.Generation)))
+	} else if len(c.Stages) > 0 {
+		best := t.BestEntity().RunningFitness()
+		for stage < len(c.Stages)-1 && stage < len(c.Thresholds) &&
+			best >= c.Thresholds[stage] {
+			stage++
+		}
+		fraction = c.Stages[stage]
+	}
+
+	eligible := c.eligibleCount(fraction)
+	changed := eligible != c.eligible || stage != c.Stage
+	if c.Pacing != nil && c.eligible != 0 && eligible != c.eligible {
+		stage++
+	}
+	c.Stage, c.Fraction = stage, fraction
+	if changed {
+		c.eligible = eligible
+		c.curIdx = 0
+		if c.Observer != nil {
+			c.Observer(c.Stage, c.Fraction)
+		}
+	}
+}
+
+// MiniBatch produces the next batch from the pool of
+// eligible samples.
+func (c *CurriculumSampleSource) MiniBatch() (anysgd.SampleList, error) {
+	if c.BatchSize > c.Samples.Len() {
+		return nil, errors.New("batch size exceeds sample count")
+	}
+	c.sortSamples()
+	if c.eligible < c.BatchSize {
+		c.eligible = c.eligibleCount(c.Fraction)
+	}
+	if c.curIdx == 0 || c.curIdx+c.BatchSize > c.eligible {
+		for i := c.eligible - 1; i > 0; i-- {
+			c.Samples.Swap(i, rand.Intn(i+1))
+		}
+		c.curIdx = 0
+	}
+	subset := c.Samples.Slice(c.curIdx, c.curIdx+c.BatchSize)
+	c.curIdx += c.BatchSize
+	return subset, nil
+}
+
+// SetBatchSize sets c.BatchSize.
+func (c *CurriculumSampleSource) SetBatchSize(n int) {
+	c.BatchSize = n
+}
+
+func (c *CurriculumSampleSource) eligibleCount(fraction float64) int {
+	n := int(fraction*float64(c.Samples.Len()) + 0.5)
+	if n < c.BatchSize {
+		n = c.BatchSize
+	}
+	if n > c.Samples.Len() {
+		n = c.Samples.Len()
+	}
+	return n
+}
+
+// sortSamples sorts the samples from easiest to hardest
+// if this has not already been done.
+func (c *CurriculumSampleSource) sortSamples() {
+	if c.sorted {
+		return
+	}
+	c.sorted = true
+	sorter := &difficultySorter{List: c.Samples}
+	for i := 0; i < c.Samples.Len(); i++ {
+		sorter.Difficulties = append(sorter.Difficulties, c.Difficulty(c.Samples, i))
+	}
+	sort.Stable(sorter)
+	if c.Pacing == nil {
+		if len(c.Stages) > 0 {
+			c.Fraction = c.Stages[0]
+		} else {
+			c.Fraction = 1
+		}
+	}
+}
+
+type difficultySorter struct {
+	List         anysgd.SampleList
+	Difficulties []float64
+}
+
+func (d *difficultySorter) Len() int {
+	return len(d.Difficulties)
+}
+
+func (d *difficultySorter) Swap(i, j int) {
+	d.List.Swap(i, j)
+	d.Difficulties[i], d.Difficulties[j] = d.Difficulties[j], d.Difficulties[i]
+}
+
+func (d *difficultySorter) Less(i, j int) bool {
+	return d.Difficulties[i] < d.Difficulties[j]
+}
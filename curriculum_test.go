@@ -0,0 +1,141 @@
+package leea
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+type curriculumStep struct {
+	Stage    int
+	Fraction float64
+}
+
+func TestCurriculumSampleSourcePacing(t *testing.T) {
+	var steps []curriculumStep
+	source := &CurriculumSampleSource{
+		Samples:    curriculumTestSamples(20),
+		Difficulty: curriculumDifficulty,
+		BatchSize:  5,
+		Pacing:     &StepSchedule{Times: []int{2, 4}, Values: []float64{0.25, 0.5, 1}},
+		Observer: func(stage int, fraction float64) {
+			steps = append(steps, curriculumStep{stage, fraction})
+		},
+	}
+	trainer := &Trainer{}
+	for gen, pool := range []int{5, 5, 10, 10, 20, 20} {
+		trainer.Generation = gen
+		source.Progress(trainer)
+		checkCurriculumCycle(t, source, pool)
+	}
+	checkCurriculumSteps(t, steps, curriculumStep{0, 0.25}, curriculumStep{1, 0.5},
+		curriculumStep{2, 1})
+}
+
+func TestCurriculumSampleSourceStages(t *testing.T) {
+	var steps []curriculumStep
+	source := &CurriculumSampleSource{
+		Samples:    curriculumTestSamples(20),
+		Difficulty: curriculumDifficulty,
+		BatchSize:  5,
+		Stages:     []float64{0.25, 0.5, 1},
+		Thresholds: []float64{1, 2},
+		Observer: func(stage int, fraction float64) {
+			steps = append(steps, curriculumStep{stage, fraction})
+		},
+	}
+
+	// Batches before any progress use the first stage.
+	checkCurriculumCycle(t, source, 5)
+
+	best := &FitEntity{}
+	trainer := &Trainer{Population: []*FitEntity{{Fitness: -10}, best}}
+	for _, x := range []struct {
+		Fitness float64
+		Stage   int
+		Pool    int
+	}{
+		{0, 0, 5},
+		{0.5, 0, 5},
+		{1.5, 1, 10},
+		{1.5, 1, 10},
+		{5, 2, 20},
+	} {
+		best.Fitness = x.Fitness
+		source.Progress(trainer)
+		if source.Stage != x.Stage {
+			t.Errorf("fitness %f: expected stage %d but got %d", x.Fitness, x.Stage,
+				source.Stage)
+		}
+		checkCurriculumCycle(t, source, x.Pool)
+	}
+	checkCurriculumSteps(t, steps, curriculumStep{1, 0.5}, curriculumStep{2, 1})
+
+	// Stages skip ahead when several thresholds are met.
+	source = &CurriculumSampleSource{
+		Samples:    curriculumTestSamples(20),
+		Difficulty: curriculumDifficulty,
+		BatchSize:  5,
+		Stages:     []float64{0.25, 0.5, 1},
+		Thresholds: []float64{1, 2},
+	}
+	source.Progress(trainer)
+	if source.Stage != 2 || source.Fraction != 1 {
+		t.Errorf("expected to skip to the last stage but got stage %d", source.Stage)
+	}
+}
+
+// checkCurriculumCycle checks that the next few batches
+// cover the easiest pool samples without repeats.
+func checkCurriculumCycle(t *testing.T, c *CurriculumSampleSource, pool int) {
+	seen := map[int]bool{}
+	for i := 0; i < pool/c.BatchSize; i++ {
+		batch, err := c.MiniBatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch.Len() != c.BatchSize {
+			t.Fatalf("expected batch size %d but got %d", c.BatchSize, batch.Len())
+		}
+		for j := 0; j < batch.Len(); j++ {
+			idx := int(curriculumDifficulty(batch, j))
+			if idx >= pool {
+				t.Fatalf("sample %d is outside of the pool of %d", idx, pool)
+			} else if seen[idx] {
+				t.Fatalf("sample %d repeated within a cycle", idx)
+			}
+			seen[idx] = true
+		}
+	}
+}
+
+func checkCurriculumSteps(t *testing.T, actual []curriculumStep, expected ...curriculumStep) {
+	if len(actual) != len(expected) {
+		t.Fatalf("expected observations %v but got %v", expected, actual)
+	}
+	for i, x := range expected {
+		if actual[i] != x {
+			t.Errorf("observation %d: expected %v but got %v", i, x, actual[i])
+		}
+	}
+}
+
+func curriculumDifficulty(s anysgd.SampleList, idx int) float64 {
+	return anyvec.Sum(s.(anyff.SliceSampleList)[idx].Input).(float64)
+}
+
+func curriculumTestSamples(n int) anyff.SliceSampleList {
+	c := anyvec64.DefaultCreator{}
+	var res anyff.SliceSampleList
+	for _, i := range rand.Perm(n) {
+		res = append(res, &anyff.Sample{
+			Input:  c.MakeVectorData([]float64{float64(i)}),
+			Output: c.MakeVectorData([]float64{0}),
+		})
+	}
+	return res
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/leea"
+	"github.com/unixpickle/leea/benchmark"
+)
+
+func main() {
+	var problemName string
+	var dim int
+	var population int
+	var generations int
+	var numSeeds int
+	var target float64
+	var crosser string
+	var mutInit, mutDecay, mutBaseline float64
+	var survivalRatio float64
+
+	flag.StringVar(&problemName, "problem", "sphere", "problem (sphere, rastrigin, "+
+		"rosenbrock, ackley, griewank, xor, parity, spirals, or regression)")
+	flag.IntVar(&dim, "dim", 10, "dimensionality of test functions")
+	flag.IntVar(&population, "population", 64, "population size")
+	flag.IntVar(&generations, "generations", 200, "maximum generations per run")
+	flag.IntVar(&numSeeds, "seeds", 10, "number of runs")
+	flag.Float64Var(&target, "target", 0, "score needed to succeed (0 for the problem's default)")
+	flag.StringVar(&crosser, "crosser", "uniform", "crosser (uniform, arithmetic, blx, sbx, neuronal, layer, or aligned)")
+	flag.Float64Var(&mutInit, "mut", 0.5, "mutation rate")
+	flag.Float64Var(&mutDecay, "mutdecay", 0.98, "mutation decay rate")
+	flag.Float64Var(&mutBaseline, "mutbias", 0.001, "mutation bias")
+	flag.Float64Var(&survivalRatio, "survival", 0.3, "survival ratio")
+	flag.Parse()
+
+	var problem benchmark.Problem
+	switch problemName {
+	case "sphere":
+		problem = benchmark.Sphere(dim)
+	case "rastrigin":
+		problem = benchmark.Rastrigin(dim)
+	case "rosenbrock":
+		problem = benchmark.Rosenbrock(dim)
+	case "ackley":
+		problem = benchmark.Ackley(dim)
+	case "griewank":
+		problem = benchmark.Griewank(dim)
+	case "xor":
+		problem = benchmark.XOR()
+	case "parity":
+		problem = benchmark.Parity(dim)
+	case "spirals":
+		problem = benchmark.Spirals(97)
+	case "regression":
+		problem = benchmark.Regression(50)
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown problem:", problemName)
+		os.Exit(1)
+	}
+
+	runner := &benchmark.Runner{
+		Problem: problem,
+		NewTrainer: func() *leea.Trainer {
+			t := &leea.Trainer{
+				Selector: &leea.TournamentSelector{Size: 3, Prob: 1},
+				Mutator: &leea.AddMutator{
+					Stddev: &leea.ExpSchedule{
+						Init:      mutInit,
+						DecayRate: mutDecay,
+						Baseline:  mutBaseline,
+					},
+				},
+				CrossOverSchedule: &leea.ExpSchedule{Baseline: 0.5},
+				SurvivalRatio:     survivalRatio,
+				Elitism:           1,
+			}
+			switch crosser {
+			case "uniform":
+				t.Crosser = &leea.UniformCrosser{}
+			case "arithmetic":
+				t.Crosser = &leea.ArithmeticCrosser{}
+			case "blx":
+				t.Crosser = &leea.BLXCrosser{Alpha: 0.5}
+			case "sbx":
+				t.Crosser = &leea.SBXCrosser{}
+			case "neuronal":
+				t.Crosser = &leea.NeuronalCrosser{}
+			case "layer":
+				t.Crosser = &leea.LayerCrosser{}
+			case "aligned":
+				t.Crosser = &leea.AlignedCrosser{Activations: true}
+			default:
+				essentials.Die("unknown crosser:", crosser)
+			}
+			return t
+		},
+		PopulationSize: population,
+		Generations:    generations,
+		Target:         target,
+	}
+	for i := 0; i < numSeeds; i++ {
+		runner.Seeds = append(runner.Seeds, int64(i))
+	}
+
+	res, err := runner.Run()
+	if err != nil {
+		essentials.Die(err)
+	}
+	for _, run := range res.Runs {
+		fmt.Printf("seed %d: success=%v generations=%d final=%f\n", run.Seed,
+			run.Success, run.Generations, run.Final)
+	}
+	fmt.Println(res)
+}
@@ -32,6 +32,8 @@ func main() {
 	var dataFile, outFile string
 	var tournamentSize int
 	var tournamentProb float64
+	var curriculum float64
+	var substitute float64
 
 	flag.Float64Var(&mutInit, "mut", 1e-2, "mutation rate")
 	flag.Float64Var(&mutDecay, "mutdecay", 0.999, "mutation decay rate")
@@ -48,6 +50,9 @@ func main() {
 	flag.IntVar(&population, "population", 16, "population size")
 	flag.IntVar(&batchSize, "batch", 64, "samples per epoch")
 
+	flag.Float64Var(&curriculum, "curriculum", 0, "curriculum pacing decay (0 disables)")
+	flag.Float64Var(&substitute, "substitute", 0, "random character substitution rate")
+
 	flag.StringVar(&dataFile, "data", "", "text data file")
 	flag.StringVar(&outFile, "file", "out_net", "saved network file")
 
@@ -59,7 +64,11 @@ func main() {
 	}
 
 	log.Println("Reading training data...")
-	samples, err := ReadSamples(dataFile)
+	minLen := MaxSentence
+	if curriculum != 0 {
+		minLen = MinSentence
+	}
+	samples, err := ReadSamples(dataFile, minLen)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to load samples:", err)
 		os.Exit(1)
@@ -96,6 +105,35 @@ func main() {
 		Inheritance:   inheritance,
 		SurvivalRatio: survivalRatio,
 	}
+	if curriculum != 0 {
+		log.Println("Using curriculum...")
+		trainer.Samples = &leea.CurriculumSampleSource{
+			Samples:    samples,
+			Difficulty: SampleLength,
+			BatchSize:  batchSize,
+			Pacing: &leea.ExpSchedule{
+				Init:      -0.9,
+				DecayRate: curriculum,
+				Baseline:  1,
+			},
+			Observer: func(stage int, fraction float64) {
+				log.Printf("curriculum stage %d: fraction=%f", stage, fraction)
+			},
+		}
+	}
+
+	if substitute != 0 {
+		trainer.Samples = &leea.AugmentSampleSource{
+			Source: trainer.Samples,
+			Augmenters: []leea.Augmenter{
+				&leea.TextAugmenter{
+					Prob:      substitute,
+					Texts:     SampleTexts,
+					FromTexts: TextSamples,
+				},
+			},
+		}
+	}
 
 	netData, err := ioutil.ReadFile(outFile)
 	if err == nil {
@@ -131,7 +169,7 @@ func main() {
 	log.Println("Training...")
 	trainer.Evolve(func() bool {
 		log.Printf("generation %d: max_fit=%f", trainer.Generation,
-			trainer.MaxFitness()/trainer.FitnessScale())
+			trainer.MaxFitness())
 		return true
 	})
 
@@ -9,9 +9,12 @@ import (
 	"github.com/unixpickle/essentials"
 )
 
-const MaxSentence = 100
+const (
+	MaxSentence = 100
+	MinSentence = 10
+)
 
-func ReadSamples(file string) (anysgd.SampleList, error) {
+func ReadSamples(file string, minLen int) (anysgd.SampleList, error) {
 	contents, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, essentials.AddCtx("read samples", err)
@@ -22,7 +25,21 @@ func ReadSamples(file string) (anysgd.SampleList, error) {
 		sentence = strings.TrimSpace(sentence)
 		if len(sentence) >= MaxSentence {
 			res = append(res, []byte(sentence[:MaxSentence]))
+		} else if len(sentence) >= minLen {
+			res = append(res, []byte(sentence))
 		}
 	}
 	return res, nil
 }
+
+func SampleLength(s anysgd.SampleList, idx int) float64 {
+	return float64(len(s.(charrnn.SampleList)[idx]))
+}
+
+func SampleTexts(s anysgd.SampleList) [][]byte {
+	return s.(charrnn.SampleList)
+}
+
+func TextSamples(texts [][]byte) anysgd.SampleList {
+	return charrnn.SampleList(texts)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/leea"
+	"github.com/unixpickle/leea/envs"
+)
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	var envName string
+	var population int
+	var hidden int
+	var episodes int
+	var mutInit, mutDecay, mutBaseline float64
+	var survivalRatio float64
+	var generations int
+	var speciate float64
+
+	flag.StringVar(&envName, "env", "cartpole",
+		"environment (cartpole, mountaincar, acrobot, or gridworld)")
+	flag.IntVar(&population, "population", 64, "population size")
+	flag.IntVar(&hidden, "hidden", 16, "hidden layer size")
+	flag.IntVar(&episodes, "episodes", 3, "episodes per evaluation")
+	flag.Float64Var(&mutInit, "mut", 0.1, "mutation rate")
+	flag.Float64Var(&mutDecay, "mutdecay", 0.99, "mutation decay rate")
+	flag.Float64Var(&mutBaseline, "mutbias", 0.01, "mutation bias")
+	flag.Float64Var(&survivalRatio, "survival", 0.2, "survival ratio")
+	flag.IntVar(&generations, "generations", 100, "number of generations")
+	flag.Float64Var(&speciate, "speciate", 0, "species distance threshold (0 disables)")
+	flag.Parse()
+
+	var newEnv func() leea.Environment
+	switch envName {
+	case "cartpole":
+		newEnv = func() leea.Environment { return &envs.CartPole{} }
+	case "mountaincar":
+		newEnv = func() leea.Environment { return &envs.MountainCar{} }
+	case "acrobot":
+		newEnv = func() leea.Environment { return &envs.Acrobot{} }
+	case "gridworld":
+		newEnv = func() leea.Environment {
+			world := envs.NewGridWorld(6, 6)
+			world.RandomStart = true
+			return world
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown environment:", envName)
+		os.Exit(1)
+	}
+
+	source := &leea.EpisodeSource{}
+	trainer := &leea.Trainer{
+		Evaluator: &leea.EpisodeEvaluator{
+			NewEnvironment: newEnv,
+			Episodes:       episodes,
+		},
+		Samples:  source,
+		Fetcher:  source,
+		Selector: &leea.TournamentSelector{Size: 5, Prob: 1},
+		Crosser:  &leea.NeuronalCrosser{},
+		Mutator: &leea.AddMutator{
+			Stddev: &leea.ExpSchedule{
+				Init:      mutInit,
+				DecayRate: mutDecay,
+				Baseline:  mutBaseline,
+			},
+		},
+		CrossOverSchedule: &leea.ExpSchedule{Baseline: 0.5},
+		Inheritance:       0,
+		SurvivalRatio:     survivalRatio,
+		Elitism:           1,
+	}
+
+	if speciate > 0 {
+		trainer.Speciation = &leea.Speciation{
+			Threshold:       speciate,
+			StagnationLimit: 15,
+			Elitism:         1,
+			Observer: func(stats []*leea.SpeciesStats) {
+				for _, st := range stats {
+					if st.Removed {
+						log.Printf("species %d removed after %d generations", st.ID, st.Age)
+					}
+				}
+				log.Printf("%d species", len(stats))
+			},
+		}
+	}
+
+	c := anyvec64.DefaultCreator{}
+	env := newEnv()
+	for i := 0; i < population; i++ {
+		trainer.Population = append(trainer.Population, &leea.FitEntity{
+			Entity: &leea.NetEntity{Parameterizer: anynet.Net{
+				anynet.NewFC(c, env.ObservationSize(), hidden),
+				anynet.Tanh,
+				anynet.NewFC(c, hidden, env.NumActions()),
+			}},
+		})
+	}
+
+	log.Println("Training...")
+	err := trainer.Evolve(func() bool {
+		log.Printf("generation %d: max_return=%f mean_return=%f", trainer.Generation,
+			trainer.MaxFitness(),
+			trainer.MeanFitness())
+		return trainer.Generation < generations
+	})
+	if err != nil {
+		essentials.Die(err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyconv"
 	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec32"
 	"github.com/unixpickle/leea"
@@ -39,6 +40,13 @@ func main() {
 	var elitism int
 	var tournamentSize int
 	var tournamentProb float64
+	var validateInterval int
+	var patience int
+	var holdout int
+	var stratify bool
+	var augment bool
+	var accuracyBonus float64
+	var l1Penalty, l2Penalty float64
 
 	flag.Float64Var(&mutInit, "mut", 0.01, "mutation rate")
 	flag.Float64Var(&mutDecay, "mutdecay", 0.999, "mutation decay rate")
@@ -60,18 +68,35 @@ func main() {
 	flag.IntVar(&batchSize, "batch", 300, "samples per epoch")
 	flag.IntVar(&elitism, "elitism", 0, "elite count")
 
+	flag.IntVar(&validateInterval, "validate", 0, "generations between validations (0 disables)")
+	flag.IntVar(&patience, "patience", 0, "validations without improvement before stopping")
+	flag.IntVar(&holdout, "holdout", 5000, "training samples held out for validation")
+
 	flag.StringVar(&outFile, "file", "out_net", "saved network file")
 	flag.BoolVar(&convolutional, "conv", false, "use convolutional network")
 	flag.BoolVar(&setMutations, "setmut", false, "use set mutations")
+	flag.BoolVar(&stratify, "stratify", false, "use class-stratified batches")
+	flag.BoolVar(&augment, "augment", false, "randomly distort training images")
+	flag.Float64Var(&accuracyBonus, "accbonus", 0, "fitness bonus for classification accuracy")
+	flag.Float64Var(&l1Penalty, "l1", 0, "L1 weight penalty (normalized)")
+	flag.Float64Var(&l2Penalty, "l2", 0, "L2 weight penalty (normalized)")
 
 	flag.Parse()
 
 	log.Println("Initializing trainer...")
+	trainSamples := anysgd.SampleList(mnist.LoadTrainingDataSet().AnyNetSamples(Creator))
+	var validationSamples anysgd.SampleList
+	if validateInterval > 0 {
+		n := trainSamples.Len()
+		validationSamples = trainSamples.Slice(n-holdout, n)
+		trainSamples = trainSamples.Slice(0, n-holdout)
+	}
+
 	trainer := &leea.Trainer{
 		Evaluator: &leea.NegCost{Cost: anynet.DotCost{}},
 		Fetcher:   &anyff.Trainer{},
 		Samples: &leea.CycleSampleSource{
-			Samples:   mnist.LoadTrainingDataSet().AnyNetSamples(Creator),
+			Samples:   trainSamples,
 			BatchSize: batchSize,
 		},
 		Selector: &leea.TournamentSelector{
@@ -89,6 +114,49 @@ func main() {
 		Elitism:       elitism,
 	}
 
+	if stratify {
+		trainer.Samples = &leea.StratifiedSampleSource{
+			Samples: trainer.Samples.(*leea.CycleSampleSource).Samples,
+			Label: func(s anysgd.SampleList, idx int) int {
+				return anyvec.MaxIndex(s.(anyff.SliceSampleList)[idx].Output)
+			},
+			BatchSize: batchSize,
+		}
+	}
+
+	if accuracyBonus != 0 {
+		trainer.Evaluator = &leea.WeightedSum{
+			Evaluators: []leea.Evaluator{trainer.Evaluator, &leea.Accuracy{}},
+			Weights:    []float64{1, accuracyBonus},
+		}
+	}
+
+	if l1Penalty != 0 || l2Penalty != 0 {
+		trainer.Evaluator = &leea.PenaltyEvaluator{
+			Evaluator: trainer.Evaluator,
+			L1:        l1Penalty,
+			L2:        l2Penalty,
+			Normalize: true,
+		}
+	}
+
+	if augment {
+		trainer.Samples = &leea.AugmentSampleSource{
+			Source: trainer.Samples,
+			Augmenters: []leea.Augmenter{
+				&leea.ImageAugmenter{
+					Width:    28,
+					Height:   28,
+					Depth:    1,
+					Shift:    2,
+					Rotation: 0.15,
+					Elastic:  0.5,
+					Dropout:  0.02,
+				},
+			},
+		}
+	}
+
 	mutSchedule := &leea.ExpSchedule{
 		Init:      mutInit,
 		DecayRate: mutDecay,
@@ -114,18 +182,37 @@ func main() {
 		}
 	}
 
+	if validateInterval > 0 {
+		trainer.Validator = &leea.Validator{
+			Samples: &leea.CycleSampleSource{
+				Samples:   validationSamples,
+				BatchSize: validationSamples.Len(),
+			},
+			Evaluator: &leea.Accuracy{},
+			Interval:  validateInterval,
+			TopK:      5,
+			Patience:  patience,
+		}
+	}
+
 	trainer.Population = populate(convolutional, outFile, population)
 
 	log.Println("Training...")
 	trainer.Evolve(func() bool {
 		log.Printf("generation %d: max_fit=%f mean_fit=%f", trainer.Generation,
-			trainer.MaxFitness()/trainer.FitnessScale(),
-			trainer.MeanFitness()/trainer.FitnessScale())
+			trainer.MaxFitness(),
+			trainer.MeanFitness())
 		return true
 	})
 
-	log.Println("Saving fittest network...")
-	net := trainer.BestEntity().Entity.(*leea.NetEntity).Parameterizer.(anynet.Net)
+	best := trainer.BestEntity().Entity
+	if trainer.Validator != nil && trainer.Validator.Best() != nil {
+		log.Println("Saving best-validated network...")
+		best = trainer.Validator.Best().Entity
+	} else {
+		log.Println("Saving fittest network...")
+	}
+	net := best.(*leea.NetEntity).Parameterizer.(anynet.Net)
 	if err := serializer.SaveAny(outFile, net); err != nil {
 		log.Println("Save failed:", err)
 	}
@@ -0,0 +1,425 @@
+package leea
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+func init() {
+	var e Ensemble
+	serializer.RegisterTypedDeserializer(e.SerializerType(), DeserializeEnsemble)
+	var b EnsembleBlock
+	serializer.RegisterTypedDeserializer(b.SerializerType(), DeserializeEnsembleBlock)
+}
+
+// An Ensemble is an anynet.Layer which averages the
+// predictions of several member layers.
+//
+// Every member should output log-probabilities, and the
+// ensemble outputs log-probabilities as well.
+type Ensemble struct {
+	Members []anynet.Layer
+
+	// Geometric indicates that log-probabilities should be
+	// averaged (and then re-normalized), rather than
+	// probabilities.
+	Geometric bool
+}
+
+// DeserializeEnsemble deserializes an Ensemble.
+func DeserializeEnsemble(d []byte) (*Ensemble, error) {
+	var members anynet.Net
+	var res Ensemble
+	if err := serializer.DeserializeAny(d, &members, &res.Geometric); err != nil {
+		return nil, essentials.AddCtx("deserialize Ensemble", err)
+	}
+	res.Members = members
+	return &res, nil
+}
+
+// Apply applies every member and averages the results.
+func (e *Ensemble) Apply(in anydiff.Res, batch int) anydiff.Res {
+	return anydiff.Pool(in, func(in anydiff.Res) anydiff.Res {
+		var outs []anydiff.Res
+		for _, m := range e.Members {
+			outs = append(outs, m.Apply(in, batch))
+		}
+		return averageLogProbs(outs, batch, e.Geometric)
+	})
+}
+
+// Parameters returns the parameters of all the members.
+func (e *Ensemble) Parameters() []*anydiff.Var {
+	return anynet.Net(e.Members).Parameters()
+}
+
+// SerializerType returns the unique ID used to serialize
+// an Ensemble with the serializer package.
+func (e *Ensemble) SerializerType() string {
+	return "github.com/unixpickle/leea.Ensemble"
+}
+
+// Serialize serializes the Ensemble if every member can
+// be serialized.
+func (e *Ensemble) Serialize() ([]byte, error) {
+	return serializer.SerializeAny(anynet.Net(e.Members), e.Geometric)
+}
+
+// An EnsembleBlock is an anyrnn.Block which averages the
+// predictions of several member blocks at every timestep.
+//
+// Like an Ensemble, every member should output
+// log-probabilities.
+type EnsembleBlock struct {
+	Members []anyrnn.Block
+
+	// Geometric has the same meaning as for Ensemble.
+	Geometric bool
+}
+
+// DeserializeEnsembleBlock deserializes an EnsembleBlock.
+func DeserializeEnsembleBlock(d []byte) (*EnsembleBlock, error) {
+	var members []serializer.Serializer
+	var res EnsembleBlock
+	if err := serializer.DeserializeAny(d, &members, &res.Geometric); err != nil {
+		return nil, essentials.AddCtx("deserialize EnsembleBlock", err)
+	}
+	for _, m := range members {
+		block, ok := m.(anyrnn.Block)
+		if !ok {
+			return nil, fmt.Errorf("deserialize EnsembleBlock: not a Block: %T", m)
+		}
+		res.Members = append(res.Members, block)
+	}
+	return &res, nil
+}
+
+// Start produces a start state.
+func (e *EnsembleBlock) Start(n int) anyrnn.State {
+	res := make(EnsembleState, len(e.Members))
+	for i, m := range e.Members {
+		res[i] = m.Start(n)
+	}
+	return res
+}
+
+// PropagateStart back-propagates through the start state.
+func (e *EnsembleBlock) PropagateStart(s anyrnn.StateGrad, g anydiff.Grad) {
+	for i, sg := range s.(EnsembleGrad) {
+		e.Members[i].PropagateStart(sg, g)
+	}
+}
+
+// Step applies every member and averages the results.
+func (e *EnsembleBlock) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	state := s.(EnsembleState)
+	batch := s.Present().NumPresent()
+	res := &ensembleBlockRes{OutState: make(EnsembleState, len(e.Members))}
+	var pooled []anydiff.Res
+	var varSets []anydiff.VarSet
+	for i, m := range e.Members {
+		r := m.Step(state[i], in)
+		pool := anydiff.NewVar(r.Output())
+		res.Reses = append(res.Reses, r)
+		res.Pools = append(res.Pools, pool)
+		res.OutState[i] = r.State()
+		pooled = append(pooled, pool)
+		varSets = append(varSets, r.Vars())
+	}
+	res.V = anydiff.MergeVarSets(varSets...)
+	res.OutRes = averageLogProbs(pooled, batch, e.Geometric)
+	return res
+}
+
+// Parameters returns the parameters of all the members.
+func (e *EnsembleBlock) Parameters() []*anydiff.Var {
+	var members []interface{}
+	for _, m := range e.Members {
+		members = append(members, m)
+	}
+	return anynet.AllParameters(members...)
+}
+
+// SerializerType returns the unique ID used to serialize
+// an EnsembleBlock with the serializer package.
+func (e *EnsembleBlock) SerializerType() string {
+	return "github.com/unixpickle/leea.EnsembleBlock"
+}
+
+// Serialize serializes the EnsembleBlock if every member
+// can be serialized.
+func (e *EnsembleBlock) Serialize() ([]byte, error) {
+	var members []serializer.Serializer
+	for _, m := range e.Members {
+		s, ok := m.(serializer.Serializer)
+		if !ok {
+			return nil, fmt.Errorf("not a Serializer: %T", m)
+		}
+		members = append(members, s)
+	}
+	return serializer.SerializeAny(members, e.Geometric)
+}
+
+// EnsembleState stores the states of an EnsembleBlock's
+// members.
+type EnsembleState []anyrnn.State
+
+// Present returns the present map of the first member.
+func (e EnsembleState) Present() anyrnn.PresentMap {
+	return e[0].Present()
+}
+
+// Reduce reduces every member state.
+func (e EnsembleState) Reduce(p anyrnn.PresentMap) anyrnn.State {
+	res := make(EnsembleState, len(e))
+	for i, s := range e {
+		res[i] = s.Reduce(p)
+	}
+	return res
+}
+
+// EnsembleGrad stores the state gradients of an
+// EnsembleBlock's members.
+type EnsembleGrad []anyrnn.StateGrad
+
+// Present returns the present map of the first member.
+func (e EnsembleGrad) Present() anyrnn.PresentMap {
+	return e[0].Present()
+}
+
+// Expand expands every member state gradient.
+func (e EnsembleGrad) Expand(p anyrnn.PresentMap) anyrnn.StateGrad {
+	res := make(EnsembleGrad, len(e))
+	for i, s := range e {
+		res[i] = s.Expand(p)
+	}
+	return res
+}
+
+type ensembleBlockRes struct {
+	Reses    []anyrnn.Res
+	Pools    []*anydiff.Var
+	OutRes   anydiff.Res
+	OutState EnsembleState
+	V        anydiff.VarSet
+}
+
+func (e *ensembleBlockRes) State() anyrnn.State {
+	return e.OutState
+}
+
+func (e *ensembleBlockRes) Output() anyvec.Vector {
+	return e.OutRes.Output()
+}
+
+func (e *ensembleBlockRes) Vars() anydiff.VarSet {
+	return e.V
+}
+
+func (e *ensembleBlockRes) Propagate(u anyvec.Vector, s anyrnn.StateGrad,
+	g anydiff.Grad) (anyvec.Vector, anyrnn.StateGrad) {
+	for _, p := range e.Pools {
+		g[p] = p.Vector.Creator().MakeVector(p.Vector.Len())
+		defer func(p *anydiff.Var) {
+			delete(g, p)
+		}(p)
+	}
+	e.OutRes.Propagate(u, g)
+
+	var inGrad anyvec.Vector
+	downGrad := make(EnsembleGrad, len(e.Reses))
+	for i, r := range e.Reses {
+		var sg anyrnn.StateGrad
+		if s != nil {
+			sg = s.(EnsembleGrad)[i]
+		}
+		in, down := r.Propagate(g[e.Pools[i]], sg, g)
+		if inGrad == nil {
+			inGrad = in
+		} else {
+			inGrad.Add(in)
+		}
+		downGrad[i] = down
+	}
+	return inGrad, downGrad
+}
+
+// An EnsembleBuilder chooses a subset of a population to
+// use as an ensemble.
+//
+// Members are chosen greedily: at every step, the
+// candidate which most improves the ensemble's score on
+// a validation batch is added.
+// Building stops when no candidate improves the score.
+// Since members are only added if they help the ensemble,
+// the result tends to be diverse.
+type EnsembleBuilder struct {
+	// Evaluator scores ensembles on the validation batch.
+	// It is called with *NetEntity objects wrapping an
+	// *Ensemble or an *EnsembleBlock.
+	Evaluator Evaluator
+
+	// Batch is the validation batch.
+	Batch anysgd.Batch
+
+	// PoolSize is the number of candidates, chosen by
+	// running fitness, to consider for the ensemble.
+	// If 0, the entire population is considered.
+	PoolSize int
+
+	// MaxSize is the maximum number of members.
+	// If 0, there is no limit.
+	MaxSize int
+
+	// Geometric is passed to the resulting ensemble.
+	Geometric bool
+}
+
+// BuildLayer builds an Ensemble from a population of
+// *NetEntity objects wrapping anynet.Layers.
+//
+// Every chosen member must implement Copier, since the
+// ensemble is made of snapshots.
+func (e *EnsembleBuilder) BuildLayer(pop []*FitEntity) (*Ensemble, error) {
+	members, err := e.build(pop, func(p []anynet.Parameterizer) anynet.Parameterizer {
+		res := &Ensemble{Geometric: e.Geometric}
+		for _, x := range p {
+			res.Members = append(res.Members, x.(anynet.Layer))
+		}
+		return res
+	})
+	if err != nil {
+		return nil, essentials.AddCtx("build ensemble", err)
+	}
+	return members.(*Ensemble), nil
+}
+
+// BuildBlock is like BuildLayer, but for populations of
+// anyrnn.Blocks.
+func (e *EnsembleBuilder) BuildBlock(pop []*FitEntity) (*EnsembleBlock, error) {
+	members, err := e.build(pop, func(p []anynet.Parameterizer) anynet.Parameterizer {
+		res := &EnsembleBlock{Geometric: e.Geometric}
+		for _, x := range p {
+			res.Members = append(res.Members, x.(anyrnn.Block))
+		}
+		return res
+	})
+	if err != nil {
+		return nil, essentials.AddCtx("build ensemble", err)
+	}
+	return members.(*EnsembleBlock), nil
+}
+
+func (e *EnsembleBuilder) build(pop []*FitEntity,
+	combine func(p []anynet.Parameterizer) anynet.Parameterizer) (anynet.Parameterizer,
+	error) {
+	if len(pop) == 0 {
+		return nil, errors.New("empty population")
+	}
+	for _, member := range pop {
+		if _, ok := member.Entity.(*NetEntity); !ok {
+			return nil, fmt.Errorf("unsupported entity: %T", member.Entity)
+		}
+	}
+	pool := append(fitnessSorter{}, pop...)
+	sort.Sort(pool)
+	if e.PoolSize != 0 && e.PoolSize < len(pool) {
+		pool = pool[:e.PoolSize]
+	}
+	candidates := make([]anynet.Parameterizer, len(pool))
+	for i, member := range pool {
+		candidates[i] = member.Entity.(*NetEntity).Parameterizer
+	}
+
+	var chosen []anynet.Parameterizer
+	bestScore := 0.0
+	for e.MaxSize == 0 || len(chosen) < e.MaxSize {
+		bestIdx := -1
+		for i, candidate := range candidates {
+			members := append(append([]anynet.Parameterizer{}, chosen...), candidate)
+			entity := &NetEntity{Parameterizer: combine(members)}
+			score, err := evaluate(e.Evaluator, entity, e.Batch)
+			if err != nil {
+				return nil, err
+			}
+			if (bestIdx == -1 && len(chosen) == 0) || score > bestScore {
+				bestIdx = i
+				bestScore = score
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+		chosen = append(chosen, candidates[bestIdx])
+		candidates = append(candidates[:bestIdx], candidates[bestIdx+1:]...)
+		if len(candidates) == 0 {
+			break
+		}
+	}
+
+	for i, member := range chosen {
+		copied, err := serializer.Copy(member)
+		if err != nil {
+			return nil, essentials.AddCtx("copy member", err)
+		}
+		p, ok := copied.(anynet.Parameterizer)
+		if !ok {
+			return nil, fmt.Errorf("copy is not a Parameterizer: %T", copied)
+		}
+		chosen[i] = p
+	}
+	return combine(chosen), nil
+}
+
+// averageLogProbs averages log-probabilities, either
+// arithmetically in probability space or geometrically.
+func averageLogProbs(outs []anydiff.Res, batch int, geometric bool) anydiff.Res {
+	c := outs[0].Output().Creator()
+	scale := c.MakeNumeric(1 / float64(len(outs)))
+	if geometric {
+		sum := outs[0]
+		for _, x := range outs[1:] {
+			sum = anydiff.Add(sum, x)
+		}
+		return anydiff.LogSoftmax(anydiff.Scale(sum, scale), sum.Output().Len()/batch)
+	}
+	sum := anydiff.Exp(outs[0])
+	for _, x := range outs[1:] {
+		sum = anydiff.Add(sum, anydiff.Exp(x))
+	}
+	return logRes(anydiff.Scale(sum, scale))
+}
+
+type logResult struct {
+	In     anydiff.Res
+	OutVec anyvec.Vector
+}
+
+// logRes computes the element-wise natural logarithm.
+func logRes(in anydiff.Res) anydiff.Res {
+	out := in.Output().Copy()
+	anyvec.Log(out)
+	return &logResult{In: in, OutVec: out}
+}
+
+func (l *logResult) Output() anyvec.Vector {
+	return l.OutVec
+}
+
+func (l *logResult) Vars() anydiff.VarSet {
+	return l.In.Vars()
+}
+
+func (l *logResult) Propagate(u anyvec.Vector, g anydiff.Grad) {
+	u.Div(l.In.Output())
+	l.In.Propagate(u, g)
+}
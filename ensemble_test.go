@@ -0,0 +1,80 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/serializer"
+)
+
+func TestEnsemble(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	var members []anynet.Layer
+	for i := 0; i < 3; i++ {
+		members = append(members, anynet.Net{
+			anynet.NewFC(c, 4, 3),
+			anynet.LogSoftmax,
+		})
+	}
+	in := anydiff.NewConst(c.MakeVectorData(c.MakeNumericList([]float64{
+		1, -2, 0.5, 3, 0.2, 0.1, -1, 2,
+	})))
+
+	for _, geometric := range []bool{false, true} {
+		ensemble := &Ensemble{Members: members, Geometric: geometric}
+		actual := ensemble.Apply(in, 2).Output().Data().([]float64)
+
+		expected := make([]float64, 6)
+		for _, m := range members {
+			out := m.Apply(in, 2).Output().Copy()
+			if !geometric {
+				anyvec.Exp(out)
+			}
+			for i, x := range out.Data().([]float64) {
+				expected[i] += x / 3
+			}
+		}
+		if geometric {
+			vec := c.MakeVectorData(expected)
+			anyvec.LogSoftmax(vec, 3)
+			expected = vec.Data().([]float64)
+		} else {
+			for i, x := range expected {
+				expected[i] = math.Log(x)
+			}
+		}
+		for i, x := range expected {
+			if math.Abs(x-actual[i]) > 1e-5 {
+				t.Errorf("geometric=%v: expected %v but got %v", geometric,
+					expected, actual)
+				break
+			}
+		}
+
+		data, err := serializer.SerializeAny(ensemble)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded *Ensemble
+		if err := serializer.DeserializeAny(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Members) != 3 || decoded.Geometric != geometric {
+			t.Errorf("bad decoded ensemble: %v", decoded)
+		}
+	}
+}
+
+func TestEnsembleBuilderUnsupported(t *testing.T) {
+	builder := &EnsembleBuilder{
+		Evaluator: &ObjectiveEvaluator{Objective: func(x []float64) float64 { return 0 }},
+	}
+	pop := []*FitEntity{{Entity: NewVectorEntity([]float64{1, 2})}}
+	if _, err := builder.BuildLayer(pop); err == nil {
+		t.Error("expected an error for a non-NetEntity population")
+	}
+}
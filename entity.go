@@ -1,6 +1,12 @@
 package leea
 
-import "github.com/unixpickle/anynet"
+import (
+	"sync/atomic"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
 
 // An Entity has a set of mutable parameters.
 type Entity interface {
@@ -12,10 +18,59 @@ type Entity interface {
 	Set(e1 Entity)
 }
 
+// A Copier is an Entity which can produce deep copies of
+// itself.
+type Copier interface {
+	Entity
+
+	Copy() (Entity, error)
+}
+
+// A Versioned Entity tracks changes to its parameters.
+//
+// Versions are unique across all entities, so two
+// entities with the same version have the same
+// parameters.
+// Set should copy the version of its argument, and Decay
+// should produce a new version.
+// Since mutators and crossers modify parameters
+// directly, the built-in ones call Touch themselves, and
+// the Trainer also calls Touch after mutation and
+// cross-over in case a custom one does not.
+type Versioned interface {
+	Entity
+
+	// Version returns the current version.
+	Version() uint64
+
+	// Touch marks the parameters as changed, producing a
+	// new version.
+	Touch()
+}
+
+var versionCounter uint64
+
+func nextVersion() uint64 {
+	return atomic.AddUint64(&versionCounter, 1)
+}
+
+// touchEntity calls Touch if e is Versioned.
+func touchEntity(e Entity) {
+	if v, ok := e.(Versioned); ok {
+		v.Touch()
+	}
+}
+
 // A NetEntity wraps an anynet.Parameterizer and
 // implements the entity facilities.
+//
+// If the parameters are modified by anything besides the
+// built-in mutators and crossers, Touch should be called
+// afterwards.
 type NetEntity struct {
 	anynet.Parameterizer
+
+	version uint64
 }
 
 // Decay applies weight decay.
@@ -23,12 +78,45 @@ func (n *NetEntity) Decay(r float64) {
 	for _, p := range n.Parameterizer.Parameters() {
 		p.Vector.Scale(p.Vector.Creator().MakeNumeric(1 - r))
 	}
+	n.Touch()
 }
 
-// Set copies the parameters from e1.
+// Set copies the parameters and version from e1.
 func (n *NetEntity) Set(e1 Entity) {
-	p1 := e1.(*NetEntity).Parameterizer.Parameters()
+	n1 := e1.(*NetEntity)
+	p1 := n1.Parameterizer.Parameters()
 	for i, x := range n.Parameterizer.Parameters() {
 		x.Vector.Set(p1[i].Vector)
 	}
+	atomic.StoreUint64(&n.version, n1.Version())
+}
+
+// Version returns the version of the parameters.
+// An entity is assigned a version the first time this is
+// called.
+func (n *NetEntity) Version() uint64 {
+	if v := atomic.LoadUint64(&n.version); v != 0 {
+		return v
+	}
+	atomic.CompareAndSwapUint64(&n.version, 0, nextVersion())
+	return atomic.LoadUint64(&n.version)
+}
+
+// Touch gives the entity a new version.
+func (n *NetEntity) Touch() {
+	atomic.StoreUint64(&n.version, nextVersion())
+}
+
+// Copy creates a deep copy of the entity.
+// This only works if the Parameterizer can be copied with
+// serializer.Copy.
+func (n *NetEntity) Copy() (Entity, error) {
+	p, err := serializer.Copy(n.Parameterizer)
+	if err != nil {
+		return nil, essentials.AddCtx("copy entity", err)
+	}
+	return &NetEntity{
+		Parameterizer: p.(anynet.Parameterizer),
+		version:       n.Version(),
+	}, nil
 }
@@ -0,0 +1,115 @@
+package envs
+
+import (
+	"math"
+	"math/rand"
+)
+
+// An Acrobot is the classic task of swinging up a
+// two-link pendulum by applying torque at the joint
+// between the links.
+//
+// The dynamics follow Sutton and Barto, integrated with
+// fourth-order Runge-Kutta.
+// Observations are the cosines and sines of both joint
+// angles followed by both angular velocities.
+// The three actions apply a torque of -1, 0, and 1.
+// A reward of -1 is given for every step until the tip
+// swings above the goal height.
+type Acrobot struct {
+	// MaxSteps is the maximum episode length.
+	// If 0, 500 is used.
+	MaxSteps int
+
+	state [4]float64
+	steps int
+}
+
+// ObservationSize returns 6.
+func (a *Acrobot) ObservationSize() int {
+	return 6
+}
+
+// NumActions returns 3.
+func (a *Acrobot) NumActions() int {
+	return 3
+}
+
+// Reset starts a new episode hanging down.
+func (a *Acrobot) Reset(r *rand.Rand) []float64 {
+	for i := range a.state {
+		a.state[i] = uniform(r, -0.1, 0.1)
+	}
+	a.steps = 0
+	return a.obs()
+}
+
+// Step applies a torque for one time step.
+func (a *Acrobot) Step(action int) ([]float64, float64, bool) {
+	const dt = 0.2
+	torque := float64(action - 1)
+
+	s := a.state
+	k1 := acrobotDeriv(s, torque)
+	k2 := acrobotDeriv(addScaled(s, k1, dt/2), torque)
+	k3 := acrobotDeriv(addScaled(s, k2, dt/2), torque)
+	k4 := acrobotDeriv(addScaled(s, k3, dt), torque)
+	for i := range s {
+		s[i] += dt / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+	}
+	s[0] = wrapAngle(s[0])
+	s[1] = wrapAngle(s[1])
+	s[2] = math.Max(-4*math.Pi, math.Min(4*math.Pi, s[2]))
+	s[3] = math.Max(-9*math.Pi, math.Min(9*math.Pi, s[3]))
+	a.state = s
+	a.steps++
+
+	if -math.Cos(s[0])-math.Cos(s[0]+s[1]) > 1 {
+		return a.obs(), 0, true
+	}
+	return a.obs(), -1, a.steps >= defaultInt(a.MaxSteps, 500)
+}
+
+func (a *Acrobot) obs() []float64 {
+	s := a.state
+	return []float64{math.Cos(s[0]), math.Sin(s[0]), math.Cos(s[1]), math.Sin(s[1]),
+		s[2], s[3]}
+}
+
+func acrobotDeriv(s [4]float64, torque float64) [4]float64 {
+	const (
+		m1, m2   = 1.0, 1.0
+		l1       = 1.0
+		lc1, lc2 = 0.5, 0.5
+		i1, i2   = 1.0, 1.0
+		g        = 9.8
+	)
+	theta1, theta2, dtheta1, dtheta2 := s[0], s[1], s[2], s[3]
+	d1 := m1*lc1*lc1 + m2*(l1*l1+lc2*lc2+2*l1*lc2*math.Cos(theta2)) + i1 + i2
+	d2 := m2*(lc2*lc2+l1*lc2*math.Cos(theta2)) + i2
+	phi2 := m2 * lc2 * g * math.Cos(theta1+theta2-math.Pi/2)
+	phi1 := -m2*l1*lc2*dtheta2*dtheta2*math.Sin(theta2) -
+		2*m2*l1*lc2*dtheta2*dtheta1*math.Sin(theta2) +
+		(m1*lc1+m2*l1)*g*math.Cos(theta1-math.Pi/2) + phi2
+	ddtheta2 := (torque + d2/d1*phi1 - m2*l1*lc2*dtheta1*dtheta1*math.Sin(theta2) - phi2) /
+		(m2*lc2*lc2 + i2 - d2*d2/d1)
+	ddtheta1 := -(d2*ddtheta2 + phi1) / d1
+	return [4]float64{dtheta1, dtheta2, ddtheta1, ddtheta2}
+}
+
+func addScaled(s, d [4]float64, scale float64) [4]float64 {
+	for i := range s {
+		s[i] += d[i] * scale
+	}
+	return s
+}
+
+func wrapAngle(x float64) float64 {
+	for x > math.Pi {
+		x -= 2 * math.Pi
+	}
+	for x < -math.Pi {
+		x += 2 * math.Pi
+	}
+	return x
+}
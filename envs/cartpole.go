@@ -0,0 +1,93 @@
+// Package envs implements standard control tasks for use
+// with leea.EpisodeEvaluator.
+package envs
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A CartPole is the classic task of balancing a pole on a
+// cart by pushing the cart left or right.
+//
+// The dynamics match the commonly used formulation by
+// Barto, Sutton, and Anderson.
+// Observations are the cart position, cart velocity, pole
+// angle, and pole angular velocity.
+// The two actions push the cart left and right.
+// A reward of 1 is given for every step.
+type CartPole struct {
+	// MaxSteps is the maximum episode length.
+	// If 0, 500 is used.
+	MaxSteps int
+
+	x, xDot, theta, thetaDot float64
+	steps                    int
+}
+
+// ObservationSize returns 4.
+func (c *CartPole) ObservationSize() int {
+	return 4
+}
+
+// NumActions returns 2.
+func (c *CartPole) NumActions() int {
+	return 2
+}
+
+// Reset starts a new episode near the upright position.
+func (c *CartPole) Reset(r *rand.Rand) []float64 {
+	c.x = uniform(r, -0.05, 0.05)
+	c.xDot = uniform(r, -0.05, 0.05)
+	c.theta = uniform(r, -0.05, 0.05)
+	c.thetaDot = uniform(r, -0.05, 0.05)
+	c.steps = 0
+	return c.obs()
+}
+
+// Step pushes the cart.
+func (c *CartPole) Step(action int) ([]float64, float64, bool) {
+	const (
+		gravity    = 9.8
+		cartMass   = 1.0
+		poleMass   = 0.1
+		totalMass  = cartMass + poleMass
+		halfLength = 0.5
+		poleMoment = poleMass * halfLength
+		forceMag   = 10.0
+		tau        = 0.02
+	)
+	force := forceMag
+	if action == 0 {
+		force = -forceMag
+	}
+	cos, sin := math.Cos(c.theta), math.Sin(c.theta)
+	temp := (force + poleMoment*c.thetaDot*c.thetaDot*sin) / totalMass
+	thetaAcc := (gravity*sin - cos*temp) /
+		(halfLength * (4.0/3 - poleMass*cos*cos/totalMass))
+	xAcc := temp - poleMoment*thetaAcc*cos/totalMass
+
+	c.x += tau * c.xDot
+	c.xDot += tau * xAcc
+	c.theta += tau * c.thetaDot
+	c.thetaDot += tau * thetaAcc
+	c.steps++
+
+	failed := math.Abs(c.x) > 2.4 || math.Abs(c.theta) > 12*math.Pi/180
+	return c.obs(), 1, failed || c.steps >= defaultInt(c.MaxSteps, 500)
+}
+
+func (c *CartPole) obs() []float64 {
+	return []float64{c.x, c.xDot, c.theta, c.thetaDot}
+}
+
+func uniform(r *rand.Rand, min, max float64) float64 {
+	return min + r.Float64()*(max-min)
+}
+
+func defaultInt(x, def int) int {
+	if x == 0 {
+		return def
+	}
+	return x
+}
@@ -0,0 +1,113 @@
+package envs
+
+import "math/rand"
+
+// A GridWorld is a discrete maze in which an agent must
+// walk from a start cell to a goal cell.
+//
+// Observations are one-hot encodings of the agent's cell,
+// in row-major order.
+// The four actions move up, right, down, and left.
+// Moves into walls or off the grid leave the agent in
+// place.
+// Every step costs StepCost, and reaching the goal gives
+// a reward of 1.
+type GridWorld struct {
+	Width  int
+	Height int
+
+	// Walls marks the blocked cells in row-major order.
+	// If nil, there are no walls.
+	Walls []bool
+
+	// Start and Goal are the (x, y) coordinates of the
+	// start and goal cells.
+	Start [2]int
+	Goal  [2]int
+
+	// RandomStart indicates that episodes should start in
+	// a random open cell other than the goal.
+	RandomStart bool
+
+	// StepCost is subtracted from the reward at every
+	// step.
+	StepCost float64
+
+	// MaxSteps is the maximum episode length.
+	// If 0, 4*Width*Height is used.
+	MaxSteps int
+
+	pos   [2]int
+	steps int
+}
+
+// NewGridWorld creates an open grid world with the start
+// and goal in opposite corners.
+func NewGridWorld(width, height int) *GridWorld {
+	return &GridWorld{
+		Width:    width,
+		Height:   height,
+		Goal:     [2]int{width - 1, height - 1},
+		StepCost: 0.01,
+	}
+}
+
+// ObservationSize returns the number of cells.
+func (g *GridWorld) ObservationSize() int {
+	return g.Width * g.Height
+}
+
+// NumActions returns 4.
+func (g *GridWorld) NumActions() int {
+	return 4
+}
+
+// Reset moves the agent to the start.
+func (g *GridWorld) Reset(r *rand.Rand) []float64 {
+	g.pos = g.Start
+	if g.RandomStart {
+		var cells [][2]int
+		for y := 0; y < g.Height; y++ {
+			for x := 0; x < g.Width; x++ {
+				cell := [2]int{x, y}
+				if cell != g.Goal && !g.blocked(cell) {
+					cells = append(cells, cell)
+				}
+			}
+		}
+		if len(cells) > 0 {
+			g.pos = cells[r.Intn(len(cells))]
+		}
+	}
+	g.steps = 0
+	return g.obs()
+}
+
+// Step moves the agent.
+func (g *GridWorld) Step(action int) ([]float64, float64, bool) {
+	moves := [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+	next := g.pos
+	next[0] += moves[action][0]
+	next[1] += moves[action][1]
+	if next[0] >= 0 && next[1] >= 0 && next[0] < g.Width && next[1] < g.Height &&
+		!g.blocked(next) {
+		g.pos = next
+	}
+	g.steps++
+
+	reward := -g.StepCost
+	if g.pos == g.Goal {
+		return g.obs(), reward + 1, true
+	}
+	return g.obs(), reward, g.steps >= defaultInt(g.MaxSteps, 4*g.Width*g.Height)
+}
+
+func (g *GridWorld) blocked(cell [2]int) bool {
+	return g.Walls != nil && g.Walls[cell[1]*g.Width+cell[0]]
+}
+
+func (g *GridWorld) obs() []float64 {
+	res := make([]float64, g.Width*g.Height)
+	res[g.pos[1]*g.Width+g.pos[0]] = 1
+	return res
+}
@@ -0,0 +1,60 @@
+package envs
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A MountainCar is the classic task of driving an
+// underpowered car up a hill by building momentum.
+//
+// Observations are the position and velocity of the car.
+// The three actions push left, do nothing, and push
+// right.
+// A reward of -1 is given for every step until the car
+// reaches the goal.
+type MountainCar struct {
+	// MaxSteps is the maximum episode length.
+	// If 0, 200 is used.
+	MaxSteps int
+
+	pos, vel float64
+	steps    int
+}
+
+// ObservationSize returns 2.
+func (m *MountainCar) ObservationSize() int {
+	return 2
+}
+
+// NumActions returns 3.
+func (m *MountainCar) NumActions() int {
+	return 3
+}
+
+// Reset starts a new episode at the bottom of the valley.
+func (m *MountainCar) Reset(r *rand.Rand) []float64 {
+	m.pos = uniform(r, -0.6, -0.4)
+	m.vel = 0
+	m.steps = 0
+	return m.obs()
+}
+
+// Step accelerates the car.
+func (m *MountainCar) Step(action int) ([]float64, float64, bool) {
+	m.vel += float64(action-1)*0.001 - 0.0025*math.Cos(3*m.pos)
+	m.vel = math.Max(-0.07, math.Min(0.07, m.vel))
+	m.pos += m.vel
+	m.pos = math.Max(-1.2, math.Min(0.6, m.pos))
+	if m.pos == -1.2 && m.vel < 0 {
+		m.vel = 0
+	}
+	m.steps++
+
+	reached := m.pos >= 0.5
+	return m.obs(), -1, reached || m.steps >= defaultInt(m.MaxSteps, 200)
+}
+
+func (m *MountainCar) obs() []float64 {
+	return []float64{m.pos, m.vel}
+}
@@ -0,0 +1,241 @@
+package leea
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// An Environment is an episodic control task with a
+// discrete set of actions.
+//
+// See the envs package for some standard environments.
+type Environment interface {
+	// ObservationSize returns the length of every
+	// observation.
+	ObservationSize() int
+
+	// NumActions returns the number of possible actions.
+	NumActions() int
+
+	// Reset starts a new episode and returns the first
+	// observation.
+	Reset(r *rand.Rand) []float64
+
+	// Step takes an action and returns the next
+	// observation, the reward, and whether or not the
+	// episode has ended.
+	Step(action int) (obs []float64, reward float64, done bool)
+}
+
+// An EpisodeBatch determines the random episodes run by an
+// EpisodeEvaluator, so that every entity can be evaluated
+// on the same episodes.
+type EpisodeBatch struct {
+	Seed int64
+}
+
+// An EpisodeSource is a SampleSource and an
+// anysgd.Fetcher which produces a new *EpisodeBatch for
+// every mini-batch.
+// It can be used as both the Samples and the Fetcher of a
+// Trainer with an EpisodeEvaluator.
+type EpisodeSource struct{}
+
+// MiniBatch produces a list with a random seed.
+func (e *EpisodeSource) MiniBatch() (anysgd.SampleList, error) {
+	return episodeSeeds{rand.Int63()}, nil
+}
+
+// Fetch produces an *EpisodeBatch from a list produced by
+// MiniBatch.
+func (e *EpisodeSource) Fetch(s anysgd.SampleList) (anysgd.Batch, error) {
+	seeds, ok := s.(episodeSeeds)
+	if !ok || len(seeds) != 1 {
+		return nil, errors.New("fetch episodes: unexpected sample list")
+	}
+	return &EpisodeBatch{Seed: seeds[0]}, nil
+}
+
+type episodeSeeds []int64
+
+func (e episodeSeeds) Len() int {
+	return len(e)
+}
+
+func (e episodeSeeds) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+func (e episodeSeeds) Slice(i, j int) anysgd.SampleList {
+	return append(episodeSeeds{}, e[i:j]...)
+}
+
+// An EpisodeEvaluator is an Evaluator which runs a policy
+// network in an Environment and computes the mean return
+// over several episodes.
+//
+// The policy must be a *NetEntity wrapping an
+// anynet.Layer or an anyrnn.Block.
+// It is given one observation at a time, and produces one
+// output per action.
+//
+// If the batch is an *EpisodeBatch, the episodes are
+// determined by its seed.
+// Otherwise, the batch is ignored and random episodes are
+// used.
+type EpisodeEvaluator struct {
+	// NewEnvironment creates an environment.
+	// It is called once per evaluation.
+	NewEnvironment func() Environment
+
+	// Episodes is the number of episodes to average over.
+	// If 0, one episode is used.
+	Episodes int
+
+	// MaxSteps, if non-zero, limits the length of every
+	// episode.
+	MaxSteps int
+
+	// Stochastic indicates that actions should be sampled
+	// by treating the outputs of the policy as log
+	// probabilities.
+	// Otherwise, the action with the largest output is
+	// taken.
+	Stochastic bool
+}
+
+// Evaluate computes the mean return.
+// It panics if the entity is unsupported.
+func (e *EpisodeEvaluator) Evaluate(entity Entity, b anysgd.Batch) float64 {
+	res, err := e.EvaluateErr(entity, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr computes the mean return.
+func (e *EpisodeEvaluator) EvaluateErr(entity Entity, b anysgd.Batch) (float64, error) {
+	netEntity, ok := entity.(*NetEntity)
+	if !ok {
+		return 0, fmt.Errorf("evaluate episodes: unsupported entity: %T", entity)
+	}
+	var seed int64
+	if batch, ok := b.(*EpisodeBatch); ok {
+		seed = batch.Seed
+	} else {
+		seed = rand.Int63()
+	}
+	gen := rand.New(rand.NewSource(seed))
+
+	env := e.NewEnvironment()
+	numEpisodes := e.Episodes
+	if numEpisodes == 0 {
+		numEpisodes = 1
+	}
+	var total float64
+	for i := 0; i < numEpisodes; i++ {
+		policy, err := newEpisodePolicy(netEntity.Parameterizer)
+		if err != nil {
+			return 0, err
+		}
+		ret, err := e.runEpisode(env, policy, gen)
+		if err != nil {
+			return 0, err
+		}
+		total += ret
+	}
+	return total / float64(numEpisodes), nil
+}
+
+func (e *EpisodeEvaluator) runEpisode(env Environment, policy *episodePolicy,
+	gen *rand.Rand) (float64, error) {
+	obs := env.Reset(gen)
+	var ret float64
+	for step := 0; e.MaxSteps == 0 || step < e.MaxSteps; step++ {
+		if len(obs) != env.ObservationSize() {
+			return 0, errors.New("evaluate episodes: bad observation size")
+		}
+		out := policy.Act(obs)
+		if len(out) != env.NumActions() {
+			return 0, fmt.Errorf("evaluate episodes: policy has %d outputs but "+
+				"there are %d actions", len(out), env.NumActions())
+		}
+		var reward float64
+		var done bool
+		obs, reward, done = env.Step(e.chooseAction(out, gen))
+		ret += reward
+		if done {
+			break
+		}
+	}
+	return ret, nil
+}
+
+func (e *EpisodeEvaluator) chooseAction(out []float64, gen *rand.Rand) int {
+	if !e.Stochastic {
+		best := 0
+		for i, x := range out {
+			if x > out[best] {
+				best = i
+			}
+		}
+		return best
+	}
+	num := gen.Float64()
+	for i, x := range out {
+		num -= math.Exp(x)
+		if num < 0 {
+			return i
+		}
+	}
+	return len(out) - 1
+}
+
+// An episodePolicy applies a feed-forward or recurrent
+// network to one observation at a time.
+type episodePolicy struct {
+	Creator anyvec.Creator
+	Layer   anynet.Layer
+	Block   anyrnn.Block
+	State   anyrnn.State
+}
+
+func newEpisodePolicy(p anynet.Parameterizer) (*episodePolicy, error) {
+	params := p.Parameters()
+	if len(params) == 0 {
+		return nil, errors.New("evaluate episodes: policy has no parameters")
+	}
+	res := &episodePolicy{Creator: params[0].Vector.Creator()}
+	if block, ok := p.(anyrnn.Block); ok {
+		res.Block = block
+		res.State = block.Start(1)
+	} else if layer, ok := p.(anynet.Layer); ok {
+		res.Layer = layer
+	} else {
+		return nil, fmt.Errorf("evaluate episodes: unsupported policy: %T", p)
+	}
+	return res, nil
+}
+
+// Act computes the policy's outputs for an observation.
+func (e *episodePolicy) Act(obs []float64) []float64 {
+	in := e.Creator.MakeVectorData(e.Creator.MakeNumericList(obs))
+	var out anyvec.Vector
+	if e.Block != nil {
+		res := e.Block.Step(e.State, in)
+		e.State = res.State()
+		out = res.Output()
+	} else {
+		out = e.Layer.Apply(anydiff.NewConst(in), 1).Output()
+	}
+	return numericFloats(out.Data())
+}
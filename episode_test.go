@@ -0,0 +1,77 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/leea/envs"
+)
+
+func TestEpisodeEvaluatorGridWorld(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	world := envs.NewGridWorld(4, 3)
+
+	// Move right until the last column, then move down.
+	fc := anynet.NewFCZero(c, 12, 4)
+	weights := make([]float64, 4*12)
+	for cell := 0; cell < 12; cell++ {
+		action := 1
+		if cell%4 == 3 {
+			action = 2
+		}
+		weights[action*12+cell] = 1
+	}
+	fc.Weights.Vector.SetData(weights)
+
+	evaluator := &EpisodeEvaluator{
+		NewEnvironment: func() Environment { return world },
+		Episodes:       3,
+	}
+	actual := evaluator.Evaluate(&NetEntity{Parameterizer: fc}, nil)
+	expected := 1 - 5*world.StepCost
+	if math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected return %f but got %f", expected, actual)
+	}
+}
+
+func TestEpisodeEvaluatorSeeds(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	for _, newEnv := range []func() Environment{
+		func() Environment { return &envs.CartPole{} },
+		func() Environment { return &envs.MountainCar{} },
+		func() Environment { return &envs.Acrobot{MaxSteps: 50} },
+	} {
+		env := newEnv()
+		policy := &NetEntity{Parameterizer: anynet.Net{
+			anynet.NewFC(c, env.ObservationSize(), env.NumActions()),
+			anynet.LogSoftmax,
+		}}
+		evaluator := &EpisodeEvaluator{
+			NewEnvironment: newEnv,
+			Episodes:       2,
+			Stochastic:     true,
+		}
+		batch := &EpisodeBatch{Seed: 1337}
+		r1, err := evaluator.EvaluateErr(policy, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r2, _ := evaluator.EvaluateErr(policy, batch)
+		if r1 != r2 {
+			t.Errorf("%T: same seed gave returns %f and %f", env, r1, r2)
+		}
+		if r1 == 0 {
+			t.Errorf("%T: expected non-zero return", env)
+		}
+	}
+
+	evaluator := &EpisodeEvaluator{
+		NewEnvironment: func() Environment { return &envs.CartPole{} },
+	}
+	_, err := evaluator.EvaluateErr(&NetEntity{Parameterizer: anynet.NewFC(c, 4, 3)}, nil)
+	if err == nil {
+		t.Error("expected error for wrong number of outputs")
+	}
+}
@@ -1,15 +1,19 @@
 package leea
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anydiff/anyseq"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyff"
 	"github.com/unixpickle/anynet/anyrnn"
 	"github.com/unixpickle/anynet/anys2s"
+	"github.com/unixpickle/anynet/anys2v"
 	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
 )
 
 // An Evaluator evaluates an Entity on a sample batch.
@@ -19,51 +23,357 @@ type Evaluator interface {
 	Evaluate(e Entity, b anysgd.Batch) float64
 }
 
+// A SampleEvaluator is an Evaluator which can also
+// measure the fitness of an Entity on each sample in a
+// batch individually.
+type SampleEvaluator interface {
+	Evaluator
+
+	SampleFitnesses(e Entity, b anysgd.Batch) []float64
+}
+
+// A PopulationEvaluator is an Evaluator which can
+// evaluate many entities on a batch at once, typically
+// more efficiently than one at a time.
+type PopulationEvaluator interface {
+	Evaluator
+
+	// CanEvaluateAll returns true if EvaluateAll supports
+	// the entities and the batch.
+	CanEvaluateAll(e []Entity, b anysgd.Batch) bool
+
+	// EvaluateAll evaluates every entity on the batch.
+	EvaluateAll(e []Entity, b anysgd.Batch) []float64
+}
+
+// A FallibleEvaluator is an Evaluator which can report
+// unsupported entities or batches as errors instead of
+// panicking.
+type FallibleEvaluator interface {
+	Evaluator
+
+	EvaluateErr(e Entity, b anysgd.Batch) (float64, error)
+}
+
+// A FallibleSampleEvaluator is a SampleEvaluator which
+// can report unsupported entities or batches as errors
+// instead of panicking.
+type FallibleSampleEvaluator interface {
+	SampleEvaluator
+
+	// EvaluateSamples computes both the result of Evaluate
+	// and the result of SampleFitnesses in a single pass.
+	EvaluateSamples(e Entity, b anysgd.Batch) (float64, []float64, error)
+}
+
+// evaluate uses EvaluateErr if possible, or else
+// Evaluate.
+func evaluate(ev Evaluator, e Entity, b anysgd.Batch) (float64, error) {
+	if fe, ok := ev.(FallibleEvaluator); ok {
+		return fe.EvaluateErr(e, b)
+	}
+	return ev.Evaluate(e, b), nil
+}
+
+// evaluateSamples uses EvaluateSamples if possible, or
+// else Evaluate and SampleFitnesses.
+func evaluateSamples(ev Evaluator, e Entity, b anysgd.Batch) (float64, []float64, error) {
+	if fe, ok := ev.(FallibleSampleEvaluator); ok {
+		return fe.EvaluateSamples(e, b)
+	}
+	se, ok := ev.(SampleEvaluator)
+	if !ok {
+		return 0, nil, fmt.Errorf("not a SampleEvaluator: %T", ev)
+	}
+	fitness, err := evaluate(ev, e, b)
+	if err != nil {
+		return 0, nil, err
+	}
+	return fitness, se.SampleFitnesses(e, b), nil
+}
+
 // NegCost is an Evaluator which computes the negative
 // cost for a feed-forward or recurrent neural network.
 //
-// It expects batches of the type *anyff.Batch or
-// *anys2s.Batch.
+// It supports the following combinations of batches and
+// networks:
+//
+//     *anyff.Batch with an anynet.Layer
+//     *anys2s.Batch with an anyrnn.Block
+//     *anys2v.Batch with an anyrnn.Block
+//
+// For *anys2v.Batch, only the final output of each
+// sequence is scored.
+//
+// For *anys2s.Batch, the desired sequences may be shorter
+// than the input sequences, or have gaps, in which case
+// only the timesteps with desired outputs are scored.
 type NegCost struct {
 	Cost anynet.Cost
+
+	// LastStep indicates that only the final desired
+	// output of each sequence in an *anys2s.Batch should
+	// be scored.
+	LastStep bool
+
+	// MaskZero indicates that desired outputs which are
+	// entirely zero should be ignored.
+	MaskZero bool
+
+	// MaxStack is the maximum number of networks which
+	// EvaluateAll runs at once.
+	// If 0, all of the networks are run at once.
+	MaxStack int
 }
 
-// Evaluate computes the negative cost.
-// In order for this to work, e must be a *NetEntity, the
-// net must be an anynet.Layer or an anyrnn.Block, and the
-// batch must be an *anyff.Batch or *anys2s.Batch.
+// Evaluate computes the mean negative cost.
+// It panics if the entity or batch is unsupported.
 func (n *NegCost) Evaluate(e Entity, s anysgd.Batch) float64 {
-	var cost anyvec.NumericList
+	res, err := n.EvaluateErr(e, s)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr computes the mean negative cost.
+// In order for this to work, e must be a *NetEntity and
+// the network and batch must be a supported combination.
+func (n *NegCost) EvaluateErr(e Entity, s anysgd.Batch) (float64, error) {
+	costs, mask, err := n.costs(e, s)
+	if err != nil {
+		return 0, err
+	}
+	return meanNegCost(costs, mask)
+}
+
+// SampleFitnesses computes the negative cost for every
+// sample in the batch.
+// Samples which are masked out have a fitness of 0.
+//
+// This is supported for *anyff.Batch, *anys2v.Batch, and
+// *anys2s.Batch when LastStep is set.
+// It panics if the entity or batch is unsupported.
+func (n *NegCost) SampleFitnesses(e Entity, s anysgd.Batch) []float64 {
+	_, res, err := n.EvaluateSamples(e, s)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateSamples computes the mean negative cost and the
+// negative cost for every sample in the batch.
+// It supports the same batches as SampleFitnesses.
+func (n *NegCost) EvaluateSamples(e Entity, s anysgd.Batch) (float64, []float64, error) {
+	if b, ok := s.(*anys2s.Batch); ok && !n.LastStep {
+		return 0, nil, fmt.Errorf("evaluate negative cost: unsupported batch type "+
+			"without LastStep: %T", b)
+	}
+	costs, mask, err := n.costs(e, s)
+	if err != nil {
+		return 0, nil, err
+	}
+	fitness, err := meanNegCost(costs, mask)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i, x := range costs {
+		if mask == nil || mask[i] {
+			costs[i] = -x
+		} else {
+			costs[i] = 0
+		}
+	}
+	return fitness, costs, nil
+}
 
+// costs computes the cost of every scored output, along
+// with a mask indicating which costs count (or nil if
+// every cost counts).
+func (n *NegCost) costs(e Entity, s anysgd.Batch) ([]float64, []bool, error) {
+	netEntity, ok := e.(*NetEntity)
+	if !ok {
+		return nil, nil, fmt.Errorf("evaluate negative cost: unsupported entity: %T", e)
+	}
+
+	var actual, desired anyvec.Vector
+	var num int
+	var err error
 	switch batch := s.(type) {
 	case *anyff.Batch:
-		net := e.(*NetEntity).Parameterizer.(anynet.Layer)
-		trainer := &anyff.Trainer{
-			Net:     net,
-			Cost:    n.Cost,
-			Average: true,
+		net, ok := netEntity.Parameterizer.(anynet.Layer)
+		if !ok {
+			return nil, nil, fmt.Errorf("evaluate negative cost: %T is not an anynet.Layer",
+				netEntity.Parameterizer)
+		}
+		actual = net.Apply(batch.Inputs, batch.Num).Output()
+		desired, num = batch.Outputs.Output(), batch.Num
+	case *anys2v.Batch:
+		block, ok := netEntity.Parameterizer.(anyrnn.Block)
+		if !ok {
+			return nil, nil, fmt.Errorf("evaluate negative cost: %T is not an anyrnn.Block",
+				netEntity.Parameterizer)
+		}
+		actual, err = lastOutputs(anyrnn.Map(batch.Inputs, block).Output())
+		desired = batch.Outputs.Output()
+		if len(batch.Inputs.Output()) > 0 {
+			num = len(batch.Inputs.Output()[0].Present)
 		}
-		cost = trainer.TotalCost(batch).Output().Data()
 	case *anys2s.Batch:
-		block := e.(*NetEntity).Parameterizer.(anyrnn.Block)
-		tr := &anys2s.Trainer{
-			Func: func(s anyseq.Seq) anyseq.Seq {
-				return anyrnn.Map(s, block)
-			},
-			Cost:    n.Cost,
-			Average: true,
-		}
-		cost = tr.TotalCost(batch).Output().Data()
+		block, ok := netEntity.Parameterizer.(anyrnn.Block)
+		if !ok {
+			return nil, nil, fmt.Errorf("evaluate negative cost: %T is not an anyrnn.Block",
+				netEntity.Parameterizer)
+		}
+		actual, desired, num, err = n.alignSeqs(anyrnn.Map(batch.Inputs, block).Output(),
+			batch.Outputs.Output())
 	default:
-		panic(fmt.Sprintf("unsupported batch type: %T", batch))
+		return nil, nil, fmt.Errorf("evaluate negative cost: unsupported batch type: %T", s)
+	}
+	if err != nil {
+		return nil, nil, essentials.AddCtx("evaluate negative cost", err)
+	}
+	if num == 0 || actual.Len()%num != 0 || desired.Len()%num != 0 {
+		return nil, nil, errors.New("evaluate negative cost: mismatching output shapes")
+	}
+
+	costs := numericFloats(n.Cost.Cost(anydiff.NewConst(desired), anydiff.NewConst(actual),
+		num).Output().Data())
+
+	var mask []bool
+	if n.MaskZero {
+		mask = make([]bool, num)
+		desiredData := numericFloats(desired.Data())
+		size := len(desiredData) / num
+		for i := range mask {
+			for _, x := range desiredData[i*size : (i+1)*size] {
+				if x != 0 {
+					mask[i] = true
+					break
+				}
+			}
+		}
 	}
 
-	switch cost := cost.(type) {
+	return costs, mask, nil
+}
+
+// alignSeqs packs the actual and desired outputs for
+// every timestep that has a desired output.
+func (n *NegCost) alignSeqs(actual, desired []*anyseq.Batch) (anyvec.Vector,
+	anyvec.Vector, int, error) {
+	if len(desired) > len(actual) {
+		return nil, nil, 0, errors.New("desired sequences longer than outputs")
+	} else if len(desired) == 0 {
+		return nil, nil, 0, errors.New("no desired outputs")
+	}
+
+	if n.LastStep {
+		actualLast, err := lastOutputsBefore(actual, desired)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		desiredLast, err := lastOutputs(desired)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return actualLast, desiredLast, len(desired[0].Present), nil
+	}
+
+	var actualVecs, desiredVecs []anyvec.Vector
+	var num int
+	for t, d := range desired {
+		a := actual[t]
+		if len(a.Present) != len(d.Present) {
+			return nil, nil, 0, errors.New("mismatching batch sizes")
+		}
+		for i, p := range d.Present {
+			if p && !a.Present[i] {
+				return nil, nil, 0, errors.New("desired sequences longer than outputs")
+			}
+		}
+		if d.NumPresent() == 0 {
+			continue
+		}
+		actualVecs = append(actualVecs, a.Reduce(d.Present).Packed)
+		desiredVecs = append(desiredVecs, d.Packed)
+		num += d.NumPresent()
+	}
+	c := actual[0].Packed.Creator()
+	return c.Concat(actualVecs...), c.Concat(desiredVecs...), num, nil
+}
+
+func meanNegCost(costs []float64, mask []bool) (float64, error) {
+	var sum float64
+	var count int
+	for i, x := range costs {
+		if mask == nil || mask[i] {
+			sum += x
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, errors.New("evaluate negative cost: no outputs to score")
+	}
+	return -sum / float64(count), nil
+}
+
+// lastOutputs packs the final vector of every sequence.
+func lastOutputs(seq []*anyseq.Batch) (anyvec.Vector, error) {
+	return lastOutputsBefore(seq, seq)
+}
+
+// lastOutputsBefore packs the vector from seq at the
+// final timestep of each sequence in ref.
+func lastOutputsBefore(seq, ref []*anyseq.Batch) (anyvec.Vector, error) {
+	if len(ref) == 0 || len(seq) == 0 {
+		return nil, errors.New("empty sequence batch")
+	} else if len(seq[0].Present) != len(ref[0].Present) {
+		return nil, errors.New("mismatching batch sizes")
+	}
+	var res []anyvec.Vector
+	for i := range ref[0].Present {
+		last := -1
+		for t, b := range ref {
+			if b.Present[i] {
+				last = t
+			}
+		}
+		if last == -1 {
+			return nil, errors.New("empty sequence")
+		} else if last >= len(seq) || !seq[last].Present[i] {
+			return nil, errors.New("desired sequences longer than outputs")
+		}
+		res = append(res, packedVector(seq[last], i))
+	}
+	return seq[0].Packed.Creator().Concat(res...), nil
+}
+
+// packedVector extracts the vector for the sequence at
+// the given index from a batch.
+func packedVector(b *anyseq.Batch, seqIdx int) anyvec.Vector {
+	var idx int
+	for _, p := range b.Present[:seqIdx] {
+		if p {
+			idx++
+		}
+	}
+	size := b.Packed.Len() / b.NumPresent()
+	return b.Packed.Slice(idx*size, (idx+1)*size)
+}
+
+func numericFloats(n anyvec.NumericList) []float64 {
+	switch n := n.(type) {
 	case []float64:
-		return -cost[0]
+		return append([]float64{}, n...)
 	case []float32:
-		return -float64(cost[0])
+		res := make([]float64, len(n))
+		for i, x := range n {
+			res[i] = float64(x)
+		}
+		return res
 	default:
-		panic(fmt.Sprintf("unsupported numeric type: %T", cost))
+		panic(fmt.Sprintf("unsupported numeric type: %T", n))
 	}
 }
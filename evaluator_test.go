@@ -0,0 +1,113 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anydiff/anyseq"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anys2s"
+	"github.com/unixpickle/anynet/anys2v"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestNegCostSequences(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	net := anynet.Net{anynet.NewFC(c, 2, 3), anynet.LogSoftmax}
+	block := &NetEntity{Parameterizer: &anyrnn.LayerBlock{Layer: net}}
+	vec := func(x ...float64) anyvec.Vector {
+		return c.MakeVectorData(x)
+	}
+
+	// The block is stateless, so every score can be
+	// computed with the feed-forward net.
+	ffCost := func(inputs, outputs []anyvec.Vector) float64 {
+		return (&NegCost{Cost: anynet.DotCost{}}).Evaluate(
+			&NetEntity{Parameterizer: net},
+			&anyff.Batch{
+				Inputs:  anydiff.NewConst(c.Concat(inputs...)),
+				Outputs: anydiff.NewConst(c.Concat(outputs...)),
+				Num:     len(inputs),
+			},
+		)
+	}
+
+	ins := [][]anyvec.Vector{
+		{vec(1, 2), vec(-1, 0.5), vec(0.3, 0.2)},
+		{vec(-2, 1)},
+	}
+	outs := [][]anyvec.Vector{
+		{vec(1, 0, 0), vec(0, 1, 0)},
+		{vec(0, 0, 1)},
+	}
+	inSeq := anyseq.ConstSeqList(c, ins)
+	outSeq := anyseq.ConstSeqList(c, outs)
+
+	tests := []struct {
+		Name      string
+		Evaluator *NegCost
+		Batch     interface{}
+		Expected  float64
+	}{
+		{
+			Name:      "s2v",
+			Evaluator: &NegCost{Cost: anynet.DotCost{}},
+			Batch: &anys2v.Batch{
+				Inputs:  inSeq,
+				Outputs: anydiff.NewConst(c.Concat(vec(0, 1, 0), vec(1, 0, 0))),
+			},
+			Expected: ffCost([]anyvec.Vector{ins[0][2], ins[1][0]},
+				[]anyvec.Vector{vec(0, 1, 0), vec(1, 0, 0)}),
+		},
+		{
+			Name:      "partial",
+			Evaluator: &NegCost{Cost: anynet.DotCost{}},
+			Batch:     &anys2s.Batch{Inputs: inSeq, Outputs: outSeq},
+			Expected: ffCost([]anyvec.Vector{ins[0][0], ins[0][1], ins[1][0]},
+				[]anyvec.Vector{outs[0][0], outs[0][1], outs[1][0]}),
+		},
+		{
+			Name:      "last",
+			Evaluator: &NegCost{Cost: anynet.DotCost{}, LastStep: true},
+			Batch:     &anys2s.Batch{Inputs: inSeq, Outputs: outSeq},
+			Expected: ffCost([]anyvec.Vector{ins[0][1], ins[1][0]},
+				[]anyvec.Vector{outs[0][1], outs[1][0]}),
+		},
+		{
+			Name:      "mask",
+			Evaluator: &NegCost{Cost: anynet.DotCost{}, MaskZero: true},
+			Batch: &anys2v.Batch{
+				Inputs:  inSeq,
+				Outputs: anydiff.NewConst(c.Concat(vec(0, 0, 0), vec(1, 0, 0))),
+			},
+			Expected: ffCost([]anyvec.Vector{ins[1][0]}, []anyvec.Vector{vec(1, 0, 0)}),
+		},
+	}
+	for _, test := range tests {
+		actual, err := test.Evaluator.EvaluateErr(block, test.Batch)
+		if err != nil {
+			t.Errorf("%s: %v", test.Name, err)
+		} else if math.Abs(actual-test.Expected) > 1e-8 {
+			t.Errorf("%s: expected %f but got %f", test.Name, test.Expected, actual)
+		}
+	}
+
+	evaluator := &NegCost{Cost: anynet.DotCost{}}
+	if _, err := evaluator.EvaluateErr(block, &anyff.Batch{}); err == nil {
+		t.Error("expected error for a block on a feed-forward batch")
+	}
+	if _, err := evaluator.EvaluateErr(block, "batch"); err == nil {
+		t.Error("expected error for an unknown batch type")
+	}
+	longOut := anyseq.ConstSeqList(c, [][]anyvec.Vector{
+		outs[0], {vec(1, 0, 0), vec(0, 1, 0)},
+	})
+	_, err := evaluator.EvaluateErr(block, &anys2s.Batch{Inputs: inSeq, Outputs: longOut})
+	if err == nil {
+		t.Error("expected error for targets longer than outputs")
+	}
+}
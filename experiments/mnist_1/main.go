@@ -53,6 +53,12 @@ func main() {
 			Mut:    mutSchedule,
 			Target: 0.1,
 		},
+		BatchSizer: &leea.ScheduleBatchSizer{
+			Schedule: &leea.StepSchedule{
+				Times:  []int{BatchIncreaseIters},
+				Values: []float64{BatchSize1, BatchSize2},
+			},
+		},
 	}
 
 	trainer.Population = populate(Population)
@@ -61,18 +67,14 @@ func main() {
 	var numSamples int
 	trainer.Evolve(func() bool {
 		log.Printf("generation %d: max_fit=%f mean_fit=%f", trainer.Generation,
-			trainer.MaxFitness()/trainer.FitnessScale(),
-			trainer.MeanFitness()/trainer.FitnessScale())
-		numSamples += cycler.BatchSize
-		if trainer.Generation == BatchIncreaseIters {
-			log.Println("Increasing batch size to", BatchSize2)
-			cycler.BatchSize = BatchSize2
-		}
+			trainer.MaxFitness(),
+			trainer.MeanFitness())
+		numSamples += trainer.BatchSizer.BatchSize(trainer)
 		if trainer.Generation%10 == 0 {
 			entity := trainer.BestEntity().Entity.(*leea.NetEntity)
 			accuracy := crossValidate(entity.Parameterizer.(anynet.Net))
 			fmt.Printf("%f,%f,%f\n", float64(numSamples)/60000, accuracy,
-				trainer.MaxFitness()/trainer.FitnessScale())
+				trainer.MaxFitness())
 		}
 		return true
 	})
@@ -0,0 +1,140 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// A HardSampleSource is a FeedbackSource which oversamples
+// the examples that the population is failing on.
+//
+// The difficulty of a sample is the negative of the mean
+// fitness the population achieved on it, clipped at zero.
+// Samples which have never been seen are assumed to have
+// the mean difficulty.
+//
+// Like CycleSampleSource, it reorders the sample list,
+// so the batches it returns are only valid until the
+// next call to MiniBatch.
+type HardSampleSource struct {
+	// Samples contains the samples to draw from.
+	Samples anysgd.SampleList
+
+	// BatchSize indicates the number of samples to return
+	// from MiniBatch().
+	BatchSize int
+
+	// Uniform is the fraction of the sampling probability
+	// which is spread uniformly across all samples,
+	// ensuring that every sample is seen now and then.
+	// It should be between 0 and 1.
+	Uniform float64
+
+	// Fade is the fraction by which each difficulty moves
+	// towards the mean difficulty after every mini-batch,
+	// allowing stale scores to fade.
+	// If this is 0, difficulties never fade.
+	Fade float64
+
+	list       *indexedList
+	difficulty []float64
+	lastSeen   []int
+	seen       []bool
+	lastBatch  []int
+	numBatches int
+}
+
+// MiniBatch samples the next batch, weighting samples by
+// their difficulty.
+func (h *HardSampleSource) MiniBatch() (anysgd.SampleList, error) {
+	if h.BatchSize > h.Samples.Len() {
+		return nil, errors.New("batch size exceeds sample count")
+	}
+	if h.list == nil {
+		h.list = newIndexedList(h.Samples)
+		h.difficulty = make([]float64, h.Samples.Len())
+		h.lastSeen = make([]int, h.Samples.Len())
+		h.seen = make([]bool, h.Samples.Len())
+	}
+
+	weights := h.Weights()
+
+	// Weighted sampling without replacement, using the
+	// method of Efraimidis and Spirakis.
+	keys := make([]float64, len(weights))
+	indices := make([]int, len(weights))
+	for i, w := range weights {
+		indices[i] = i
+		if w == 0 {
+			keys[i] = math.Inf(-1)
+		} else {
+			keys[i] = math.Log(rand.Float64()) / w
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return keys[indices[i]] > keys[indices[j]]
+	})
+	h.lastBatch = append([]int{}, indices[:h.BatchSize]...)
+	h.numBatches++
+
+	return h.list.gather(h.lastBatch), nil
+}
+
+// Feedback updates the difficulties of the samples in the
+// last mini-batch.
+func (h *HardSampleSource) Feedback(fitnesses []float64) {
+	for i, fit := range fitnesses {
+		idx := h.lastBatch[i]
+		h.difficulty[idx] = math.Max(0, -fit)
+		h.lastSeen[idx] = h.numBatches
+		h.seen[idx] = true
+	}
+}
+
+// Weights computes the (unnormalized) probability of
+// sampling each sample, indexed by the sample's position
+// in the original list.
+func (h *HardSampleSource) Weights() []float64 {
+	var mean float64
+	var numSeen int
+	for i, d := range h.difficulty {
+		if h.seen[i] {
+			mean += d
+			numSeen++
+		}
+	}
+	if numSeen > 0 {
+		mean /= float64(numSeen)
+	}
+
+	res := make([]float64, len(h.difficulty))
+	var total float64
+	for i, d := range h.difficulty {
+		if !h.seen[i] {
+			res[i] = mean
+		} else {
+			keep := math.Pow(1-h.Fade, float64(h.numBatches-h.lastSeen[i]))
+			res[i] = mean + keep*(d-mean)
+		}
+		total += res[i]
+	}
+
+	uniform := h.Uniform / float64(len(res))
+	for i, x := range res {
+		if total == 0 {
+			res[i] = 1 / float64(len(res))
+		} else {
+			res[i] = uniform + (1-h.Uniform)*x/total
+		}
+	}
+	return res
+}
+
+// SetBatchSize sets h.BatchSize.
+func (h *HardSampleSource) SetBatchSize(n int) {
+	h.BatchSize = n
+}
@@ -0,0 +1,108 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestHardSampleSourceWeights(t *testing.T) {
+	h := &HardSampleSource{
+		Samples:   hardTestSamples(4),
+		BatchSize: 2,
+		Uniform:   0.5,
+		Fade:      0.5,
+	}
+	if _, err := h.MiniBatch(); err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range h.Weights() {
+		if math.Abs(w-0.25) > 1e-8 {
+			t.Errorf("unseen sample %d: expected weight 0.25 but got %f", i, w)
+		}
+	}
+
+	// Difficulties of 1 and 3 give a mean difficulty of 2
+	// for the unseen samples.
+	batch := append([]int{}, h.lastBatch...)
+	h.Feedback([]float64{-1, -3})
+	checkHardWeights(t, h, batch, []float64{0.1875, 0.3125}, 0.25)
+
+	// After another mini-batch, the difficulties fade
+	// half way to the mean.
+	if _, err := h.MiniBatch(); err != nil {
+		t.Fatal(err)
+	}
+	checkHardWeights(t, h, batch, []float64{0.21875, 0.28125}, 0.25)
+}
+
+func TestHardSampleSourceEasy(t *testing.T) {
+	h := &HardSampleSource{Samples: hardTestSamples(3), BatchSize: 3}
+	if _, err := h.MiniBatch(); err != nil {
+		t.Fatal(err)
+	}
+	h.Feedback([]float64{1, 2, 0})
+	for i, w := range h.Weights() {
+		if math.Abs(w-1.0/3) > 1e-8 {
+			t.Errorf("sample %d: expected weight 1/3 but got %f", i, w)
+		}
+	}
+}
+
+func TestHardSampleSourceSampling(t *testing.T) {
+	h := &HardSampleSource{Samples: hardTestSamples(4), BatchSize: 2}
+	if _, err := h.MiniBatch(); err != nil {
+		t.Fatal(err)
+	}
+	batch := append([]int{}, h.lastBatch...)
+	h.Feedback([]float64{-1, -3})
+	h.BatchSize = 1
+
+	const numBatches = 20000
+	counts := make([]float64, 4)
+	for i := 0; i < numBatches; i++ {
+		if _, err := h.MiniBatch(); err != nil {
+			t.Fatal(err)
+		}
+		counts[h.lastBatch[0]]++
+	}
+	expected := []float64{2.0 / 8, 2.0 / 8, 2.0 / 8, 2.0 / 8}
+	expected[batch[0]] = 1.0 / 8
+	expected[batch[1]] = 3.0 / 8
+	for i, count := range counts {
+		if math.Abs(count/numBatches-expected[i]) > 0.02 {
+			t.Errorf("sample %d: expected frequency %f but got %f", i, expected[i],
+				count/numBatches)
+		}
+	}
+}
+
+func checkHardWeights(t *testing.T, h *HardSampleSource, batch []int, seen []float64,
+	unseen float64) {
+	weights := h.Weights()
+	for i, w := range weights {
+		expected := unseen
+		for j, idx := range batch {
+			if idx == i {
+				expected = seen[j]
+			}
+		}
+		if math.Abs(w-expected) > 1e-8 {
+			t.Errorf("sample %d: expected weight %f but got %f", i, expected, w)
+		}
+	}
+}
+
+func hardTestSamples(n int) anyff.SliceSampleList {
+	c := anyvec64.DefaultCreator{}
+	var res anyff.SliceSampleList
+	for i := 0; i < n; i++ {
+		res = append(res, &anyff.Sample{
+			Input:  c.MakeVectorData([]float64{float64(i)}),
+			Output: c.MakeVectorData([]float64{0}),
+		})
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package leea
+
+import "math"
+
+// hungarian solves the assignment problem for a square
+// cost matrix, returning the column assigned to every row
+// such that the total cost is minimized.
+//
+// Non-finite costs, which arise from NaN or infinite
+// parameters, are treated as worse than any assignment of
+// finite costs, since they would otherwise prevent the
+// algorithm from terminating.
+func hungarian(cost [][]float64) []int {
+	n := len(cost)
+	cost = finiteCosts(cost)
+
+	// Potentials and matchings are 1-indexed, with index 0
+	// acting as a sentinel column.
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	match := make([]int, n+1)
+	way := make([]int, n+1)
+	for row := 1; row <= n; row++ {
+		match[0] = row
+		col0 := 0
+		minVals := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for i := range minVals {
+			minVals[i] = math.Inf(1)
+		}
+		for match[col0] != 0 {
+			used[col0] = true
+			row0 := match[col0]
+			delta := math.Inf(1)
+			col1 := 0
+			for col := 1; col <= n; col++ {
+				if used[col] {
+					continue
+				}
+				cur := cost[row0-1][col-1] - u[row0] - v[col]
+				if cur < minVals[col] {
+					minVals[col] = cur
+					way[col] = col0
+				}
+				if minVals[col] < delta {
+					delta = minVals[col]
+					col1 = col
+				}
+			}
+			for col := 0; col <= n; col++ {
+				if used[col] {
+					u[match[col]] += delta
+					v[col] -= delta
+				} else {
+					minVals[col] -= delta
+				}
+			}
+			col0 = col1
+		}
+		for col0 != 0 {
+			col1 := way[col0]
+			match[col0] = match[col1]
+			col0 = col1
+		}
+	}
+
+	res := make([]int, n)
+	for col := 1; col <= n; col++ {
+		res[match[col]-1] = col - 1
+	}
+	return res
+}
+
+// finiteCosts replaces non-finite costs with a cost that
+// exceeds the total of any assignment of finite costs.
+// If every cost is finite, the matrix is returned as-is.
+func finiteCosts(cost [][]float64) [][]float64 {
+	var maxAbs float64
+	finite := true
+	for _, row := range cost {
+		for _, x := range row {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				finite = false
+			} else {
+				maxAbs = math.Max(maxAbs, math.Abs(x))
+			}
+		}
+	}
+	if finite {
+		return cost
+	}
+	penalty := 2*maxAbs*float64(len(cost)) + 1
+	res := make([][]float64, len(cost))
+	for i, row := range cost {
+		res[i] = make([]float64, len(row))
+		for j, x := range row {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				x = penalty
+			}
+			res[i][j] = x
+		}
+	}
+	return res
+}
@@ -0,0 +1,224 @@
+package leea
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+)
+
+// A LayerCrosser performs cross-over on entire layers at
+// a time, taking every layer wholesale from one parent.
+//
+// Containers are unwrapped as described by walkLayers, so
+// every other parameterized layer, including an entire
+// RNN cell or conv filter bank, is a single unit.
+// Both entities must be *NetEntity objects with the same
+// structure.
+type LayerCrosser struct {
+	// Contiguous indicates that the source should provide
+	// a single contiguous block of layers, rather than
+	// choosing every layer independently.
+	Contiguous bool
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (l *LayerCrosser) SetRand(r *rand.Rand) {
+	l.rng.Rand = r
+}
+
+// Cross performs cross-over.
+// It panics if the entities are unsupported.
+func (l *LayerCrosser) Cross(dest, source Entity, keep float64) {
+	if err := l.CrossErr(dest, source, keep); err != nil {
+		panic(err)
+	}
+}
+
+// CrossErr performs cross-over.
+func (l *LayerCrosser) CrossErr(dest, source Entity, keep float64) error {
+	defer touchEntity(dest)
+	pairs, err := layerPairs(dest, source)
+	if err != nil {
+		return err
+	}
+	if !l.Contiguous {
+		for _, pair := range pairs {
+			if l.rng.Float64() >= keep {
+				if err := swapLayer(pair[0], pair[1]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	count := int(math.Floor((1-keep)*float64(len(pairs)) + 0.5))
+	start := l.rng.Intn(len(pairs) - count + 1)
+	for _, pair := range pairs[start : start+count] {
+		if err := swapLayer(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A CellCrosser is like a NeuronalCrosser, except that
+// conv filter banks and RNN cells are never split.
+// Instead, every *anyconv.Conv, *anyrnn.Vanilla, and
+// *anyrnn.LSTM is taken entirely from the source with
+// probability 1-keep.
+//
+// Both entities must be *NetEntity objects with the same
+// structure.
+type CellCrosser struct {
+	// Default is used for layers which a NeuronalCrosser
+	// does not recognize.
+	// If nil, such layers cause an error.
+	Default Crosser
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (c *CellCrosser) SetRand(r *rand.Rand) {
+	c.rng.Rand = r
+	setRand(c.Default, r)
+}
+
+// Cross performs cross-over.
+// It panics if the entities are unsupported.
+func (c *CellCrosser) Cross(dest, source Entity, keep float64) {
+	if err := c.CrossErr(dest, source, keep); err != nil {
+		panic(err)
+	}
+}
+
+// CrossErr performs cross-over.
+func (c *CellCrosser) CrossErr(dest, source Entity, keep float64) error {
+	defer touchEntity(dest)
+	pairs, err := layerPairs(dest, source)
+	if err != nil {
+		return err
+	}
+	neuronal := &NeuronalCrosser{Default: c.Default, rng: c.rng}
+	for _, pair := range pairs {
+		switch pair[0].(type) {
+		case *anyconv.Conv, *anyrnn.Vanilla, *anyrnn.LSTM:
+			if c.rng.Float64() >= keep {
+				err = swapLayer(pair[0], pair[1])
+			}
+		default:
+			err = neuronal.crossLayer(pair[0], pair[1], keep)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layerPairs lists the corresponding layers of two
+// *NetEntity objects using walkLayers.
+func layerPairs(dest, source Entity) ([][2]anynet.Parameterizer, error) {
+	destNet, ok1 := dest.(*NetEntity)
+	sourceNet, ok2 := source.(*NetEntity)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("cross-over: unsupported entities: %T and %T",
+			dest, source)
+	}
+	var res [][2]anynet.Parameterizer
+	err := walkLayers(destNet.Parameterizer, sourceNet.Parameterizer,
+		func(dest, source anynet.Parameterizer) error {
+			res = append(res, [2]anynet.Parameterizer{dest, source})
+			return nil
+		})
+	return res, err
+}
+
+// walkLayers walks two networks with the same structure
+// in parallel and calls f on every pair of corresponding
+// parameterized layers.
+//
+// The following containers are unwrapped rather than
+// passed to f:
+//
+//     anynet.Net
+//     anyrnn.Stack
+//     *anyrnn.LayerBlock
+//
+// Layers without parameters are skipped.
+// An error is returned if the structures do not match.
+func walkLayers(dest, source anynet.Parameterizer,
+	f func(dest, source anynet.Parameterizer) error) error {
+	if !sameType(dest, source) {
+		return fmt.Errorf("cross-over: mismatching types: %T and %T", dest, source)
+	}
+	switch dest := dest.(type) {
+	case anynet.Net:
+		source := source.(anynet.Net)
+		if len(dest) != len(source) {
+			return errors.New("cross-over: mismatching network lengths")
+		}
+		for i, layer := range dest {
+			if p, ok := layer.(anynet.Parameterizer); ok {
+				sourceLayer, _ := source[i].(anynet.Parameterizer)
+				if err := walkLayers(p, sourceLayer, f); err != nil {
+					return err
+				}
+			}
+		}
+	case anyrnn.Stack:
+		source := source.(anyrnn.Stack)
+		if len(dest) != len(source) {
+			return errors.New("cross-over: mismatching stack lengths")
+		}
+		for i, block := range dest {
+			if p, ok := block.(anynet.Parameterizer); ok {
+				sourceBlock, _ := source[i].(anynet.Parameterizer)
+				if err := walkLayers(p, sourceBlock, f); err != nil {
+					return err
+				}
+			}
+		}
+	case *anyrnn.LayerBlock:
+		if p, ok := dest.Layer.(anynet.Parameterizer); ok {
+			pSource, _ := source.(*anyrnn.LayerBlock).Layer.(anynet.Parameterizer)
+			return walkLayers(p, pSource, f)
+		}
+	default:
+		if len(dest.Parameters()) == 0 {
+			return nil
+		}
+		return f(dest, source)
+	}
+	return nil
+}
+
+// swapLayer copies every parameter of source into dest.
+func swapLayer(dest, source anynet.Parameterizer) error {
+	destParams := dest.Parameters()
+	sourceParams := source.Parameters()
+	if len(destParams) != len(sourceParams) {
+		return fmt.Errorf("cross-over: mismatching parameters in %T", dest)
+	}
+	for i, p := range destParams {
+		if p.Vector.Len() != sourceParams[i].Vector.Len() {
+			return fmt.Errorf("cross-over: mismatching parameters in %T", dest)
+		}
+	}
+	for i, p := range destParams {
+		p.Vector.Set(sourceParams[i].Vector)
+	}
+	return nil
+}
+
+func sameType(x, y interface{}) bool {
+	return reflect.TypeOf(x) == reflect.TypeOf(y)
+}
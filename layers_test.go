@@ -0,0 +1,103 @@
+package leea
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestLayerCrosser(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	newNet := func() anyrnn.Stack {
+		return anyrnn.Stack{
+			anyrnn.NewVanilla(c, 2, 3, anynet.Tanh),
+			anyrnn.NewLSTM(c, 3, 3),
+			&anyrnn.LayerBlock{Layer: anynet.Net{
+				anynet.NewFC(c, 3, 3),
+				anynet.Tanh,
+				anynet.NewFC(c, 3, 2),
+			}},
+		}
+	}
+	for _, contiguous := range []bool{false, true} {
+		for trial := 0; trial < 10; trial++ {
+			dest := &NetEntity{Parameterizer: newNet()}
+			source := &NetEntity{Parameterizer: newNet()}
+			fillParams(dest, 1)
+			fillParams(source, 2)
+			crosser := &LayerCrosser{Contiguous: contiguous}
+			if err := crosser.CrossErr(dest, source, 0.5); err != nil {
+				t.Fatal(err)
+			}
+			pairs, _ := layerPairs(dest, source)
+			if len(pairs) != 4 {
+				t.Fatalf("expected 4 layers but got %d", len(pairs))
+			}
+			var swapped []bool
+			for _, pair := range pairs {
+				values := map[float64]bool{}
+				for _, p := range pair[0].Parameters() {
+					for _, x := range numericFloats(p.Vector.Data()) {
+						values[x] = true
+					}
+				}
+				if len(values) != 1 {
+					t.Fatalf("layer %T was split", pair[0])
+				}
+				swapped = append(swapped, values[2])
+			}
+			if contiguous {
+				var count, runs int
+				for i, s := range swapped {
+					if s {
+						count++
+						if i == 0 || !swapped[i-1] {
+							runs++
+						}
+					}
+				}
+				if count != 2 || runs != 1 {
+					t.Errorf("unexpected contiguous swap: %v", swapped)
+				}
+			}
+		}
+	}
+}
+
+func TestCellCrosser(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	newNet := func() anyrnn.Stack {
+		return anyrnn.Stack{
+			anyrnn.NewLSTM(c, 3, 20),
+			&anyrnn.LayerBlock{Layer: anynet.NewFC(c, 20, 20)},
+		}
+	}
+	dest := &NetEntity{Parameterizer: newNet()}
+	source := &NetEntity{Parameterizer: newNet()}
+	fillParams(dest, 1)
+	fillParams(source, 2)
+	if err := (&CellCrosser{}).CrossErr(dest, source, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	stack := dest.Parameterizer.(anyrnn.Stack)
+	lstmValues := map[float64]bool{}
+	for _, p := range stack[0].(anynet.Parameterizer).Parameters() {
+		for _, x := range numericFloats(p.Vector.Data()) {
+			lstmValues[x] = true
+		}
+	}
+	if len(lstmValues) != 1 {
+		t.Error("LSTM cell was split")
+	}
+	fcValues := map[float64]bool{}
+	for _, p := range stack[1].(anynet.Parameterizer).Parameters() {
+		for _, x := range numericFloats(p.Vector.Data()) {
+			fcValues[x] = true
+		}
+	}
+	if len(fcValues) != 2 {
+		t.Error("FC layer should be crossed by neuron")
+	}
+}
@@ -0,0 +1,150 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A Mater chooses cross-over partners.
+type Mater interface {
+	// Mate chooses a partner for e from a non-empty list
+	// of candidates.
+	// It may return nil to skip cross-over for e.
+	//
+	// The receiver should not modify the slice.
+	Mate(e *FitEntity, candidates []*FitEntity) *FitEntity
+}
+
+// A RandomMater chooses partners uniformly at random.
+type RandomMater struct {
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (r *RandomMater) SetRand(gen *rand.Rand) {
+	r.rng.Rand = gen
+}
+
+// Mate chooses a random candidate.
+func (r *RandomMater) Mate(e *FitEntity, candidates []*FitEntity) *FitEntity {
+	return candidates[r.rng.Intn(len(candidates))]
+}
+
+// A SelectorMater chooses partners with a Selector, so
+// that fitter candidates are more likely to mate.
+//
+// The Selector should not be shared with the Trainer.
+type SelectorMater struct {
+	Selector Selector
+}
+
+// SetRand sets the random number generator.
+func (s *SelectorMater) SetRand(r *rand.Rand) {
+	setRand(s.Selector, r)
+}
+
+// Mate selects a candidate.
+func (s *SelectorMater) Mate(e *FitEntity, candidates []*FitEntity) *FitEntity {
+	s.Selector.SetEntities(candidates, 1)
+	return s.Selector.Select()
+}
+
+// A DistanceMater chooses partners by the distance
+// between their parameters, preferring either similar
+// (assortative) or dissimilar (disassortative) partners.
+//
+// Entities must implement anynet.Parameterizer.
+type DistanceMater struct {
+	// Disassortative indicates that the farthest
+	// candidate should be chosen instead of the closest.
+	Disassortative bool
+
+	// PoolSize is the number of random candidates to
+	// compare.
+	// If 0, all candidates are compared.
+	PoolSize int
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (d *DistanceMater) SetRand(r *rand.Rand) {
+	d.rng.Rand = r
+}
+
+// Mate chooses the closest or farthest candidate from a
+// random pool.
+func (d *DistanceMater) Mate(e *FitEntity, candidates []*FitEntity) *FitEntity {
+	pool := candidates
+	if d.PoolSize != 0 && d.PoolSize < len(candidates) {
+		pool = make([]*FitEntity, d.PoolSize)
+		for i, j := range d.rng.Perm(len(candidates))[:d.PoolSize] {
+			pool[i] = candidates[j]
+		}
+	}
+	var best *FitEntity
+	bestDist := math.Inf(1)
+	for _, c := range pool {
+		dist := parameterDistance(e.Entity, c.Entity)
+		if d.Disassortative {
+			dist = -dist
+		}
+		if best == nil || dist < bestDist {
+			best = c
+			bestDist = dist
+		}
+	}
+	return best
+}
+
+// A GroupMater restricts partners to the same group, such
+// as an island or a species.
+type GroupMater struct {
+	// Group computes the group of an entity.
+	Group func(e *FitEntity) int
+
+	// Mater chooses from the candidates in the group.
+	// If nil, a RandomMater is used.
+	Mater Mater
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (g *GroupMater) SetRand(r *rand.Rand) {
+	g.rng.Rand = r
+	setRand(g.Mater, r)
+}
+
+// Mate chooses a candidate from the same group.
+// It returns nil if there is no such candidate.
+func (g *GroupMater) Mate(e *FitEntity, candidates []*FitEntity) *FitEntity {
+	group := g.Group(e)
+	var same []*FitEntity
+	for _, c := range candidates {
+		if g.Group(c) == group {
+			same = append(same, c)
+		}
+	}
+	if len(same) == 0 {
+		return nil
+	}
+	mater := g.Mater
+	if mater == nil {
+		mater = &RandomMater{rng: g.rng}
+	}
+	return mater.Mate(e, same)
+}
+
+// parameterDistance computes the Euclidean distance
+// between the parameters of two entities.
+func parameterDistance(e1, e2 Entity) float64 {
+	params2 := entityParameters(e2)
+	var sum float64
+	for i, p := range entityParameters(e1) {
+		diff := p.Vector.Copy()
+		diff.Sub(params2[i].Vector)
+		sum += diff.Creator().Float64(diff.Dot(diff))
+	}
+	return math.Sqrt(sum)
+}
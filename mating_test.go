@@ -0,0 +1,55 @@
+package leea
+
+import "testing"
+
+func TestDistanceMater(t *testing.T) {
+	e := &FitEntity{Entity: NewVectorEntity([]float64{0, 0})}
+	var candidates []*FitEntity
+	for _, x := range []float64{3, 1, 5, 2} {
+		candidates = append(candidates, &FitEntity{
+			Entity: NewVectorEntity([]float64{x, -x}),
+		})
+	}
+	if res := (&DistanceMater{}).Mate(e, candidates); res != candidates[1] {
+		t.Error("assortative mating did not choose the closest candidate")
+	}
+	res := (&DistanceMater{Disassortative: true}).Mate(e, candidates)
+	if res != candidates[2] {
+		t.Error("disassortative mating did not choose the farthest candidate")
+	}
+}
+
+func TestSelectorMater(t *testing.T) {
+	candidates := []*FitEntity{{Fitness: 1}, {Fitness: 3}, {Fitness: 2}}
+	mater := &SelectorMater{Selector: &SortSelector{}}
+	if res := mater.Mate(&FitEntity{}, candidates); res != candidates[1] {
+		t.Error("did not choose the fittest candidate")
+	}
+	if candidates[0].Fitness != 1 || candidates[1].Fitness != 3 {
+		t.Error("candidates were modified")
+	}
+}
+
+func TestGroupMater(t *testing.T) {
+	groups := map[*FitEntity]int{}
+	var candidates []*FitEntity
+	for i := 0; i < 10; i++ {
+		c := &FitEntity{}
+		groups[c] = i % 3
+		candidates = append(candidates, c)
+	}
+	e := &FitEntity{}
+	mater := &GroupMater{Group: func(e *FitEntity) int { return groups[e] }}
+	for _, group := range []int{0, 1, 2} {
+		groups[e] = group
+		for i := 0; i < 10; i++ {
+			if res := mater.Mate(e, candidates); groups[res] != group {
+				t.Fatalf("group %d: chose partner from group %d", group, groups[res])
+			}
+		}
+	}
+	groups[e] = 3
+	if mater.Mate(e, candidates) != nil {
+		t.Error("expected no partner for empty group")
+	}
+}
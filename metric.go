@@ -0,0 +1,268 @@
+package leea
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anynet/anys2s"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// A MetricEvaluator is an Evaluator which averages a
+// per-sample metric over a batch.
+//
+// It expects batches of the type *anyff.Batch or
+// *anys2s.Batch.
+// For *anys2s.Batch, the metric is computed at every
+// timestep of every sequence.
+type MetricEvaluator struct {
+	// Metric computes the fitness for a single network
+	// output, given the desired output.
+	Metric func(actual, desired anyvec.Vector) float64
+}
+
+// Evaluate computes the mean metric.
+// It panics if the entity or batch is unsupported.
+func (m *MetricEvaluator) Evaluate(e Entity, b anysgd.Batch) float64 {
+	res, err := m.EvaluateErr(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr computes the mean metric.
+// In order for this to work, e must be a *NetEntity, the
+// net must be an anynet.Layer or an anyrnn.Block, and the
+// batch must be an *anyff.Batch or *anys2s.Batch.
+func (m *MetricEvaluator) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	metrics, err := m.metrics(e, b)
+	if err != nil {
+		return 0, err
+	}
+	return meanMetric(metrics)
+}
+
+// SampleFitnesses computes the metric for every sample
+// in the batch.
+// The batch must be an *anyff.Batch and the net must be
+// an anynet.Layer.
+// It panics if the entity or batch is unsupported.
+func (m *MetricEvaluator) SampleFitnesses(e Entity, b anysgd.Batch) []float64 {
+	_, res, err := m.EvaluateSamples(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateSamples computes the mean metric and the metric
+// for every sample in the batch.
+// It supports the same batches as SampleFitnesses.
+func (m *MetricEvaluator) EvaluateSamples(e Entity, b anysgd.Batch) (float64,
+	[]float64, error) {
+	if _, ok := b.(*anyff.Batch); !ok {
+		return 0, nil, fmt.Errorf("evaluate metric: unsupported batch type: %T", b)
+	}
+	metrics, err := m.metrics(e, b)
+	if err != nil {
+		return 0, nil, err
+	}
+	fitness, err := meanMetric(metrics)
+	if err != nil {
+		return 0, nil, err
+	}
+	return fitness, metrics, nil
+}
+
+func (m *MetricEvaluator) metrics(e Entity, s anysgd.Batch) ([]float64, error) {
+	netEntity, ok := e.(*NetEntity)
+	if !ok {
+		return nil, fmt.Errorf("evaluate metric: unsupported entity: %T", e)
+	}
+	var res []float64
+	switch batch := s.(type) {
+	case *anyff.Batch:
+		net, ok := netEntity.Parameterizer.(anynet.Layer)
+		if !ok {
+			return nil, fmt.Errorf("evaluate metric: %T is not an anynet.Layer",
+				netEntity.Parameterizer)
+		}
+		out := net.Apply(batch.Inputs, batch.Num).Output()
+		res = m.packedMetrics(out, batch.Outputs.Output(), batch.Num)
+	case *anys2s.Batch:
+		block, ok := netEntity.Parameterizer.(anyrnn.Block)
+		if !ok {
+			return nil, fmt.Errorf("evaluate metric: %T is not an anyrnn.Block",
+				netEntity.Parameterizer)
+		}
+		actual := anyrnn.Map(batch.Inputs, block).Output()
+		desired := batch.Outputs.Output()
+		if len(actual) != len(desired) {
+			return nil, errors.New("evaluate metric: mismatching sequence lengths")
+		}
+		for t, step := range actual {
+			if step.NumPresent() != desired[t].NumPresent() {
+				return nil, errors.New("evaluate metric: mismatching sequence lengths")
+			}
+			res = append(res, m.packedMetrics(step.Packed, desired[t].Packed,
+				step.NumPresent())...)
+		}
+	default:
+		return nil, fmt.Errorf("evaluate metric: unsupported batch type: %T", s)
+	}
+	return res, nil
+}
+
+func meanMetric(metrics []float64) (float64, error) {
+	if len(metrics) == 0 {
+		return 0, errors.New("evaluate metric: no outputs to score")
+	}
+	var sum float64
+	for _, x := range metrics {
+		sum += x
+	}
+	return sum / float64(len(metrics)), nil
+}
+
+// packedMetrics computes the metric for each of n packed
+// outputs.
+func (m *MetricEvaluator) packedMetrics(actual, desired anyvec.Vector, n int) []float64 {
+	res := make([]float64, n)
+	if n == 0 {
+		return res
+	}
+	actualSize := actual.Len() / n
+	desiredSize := desired.Len() / n
+	for i := range res {
+		res[i] = m.Metric(actual.Slice(i*actualSize, (i+1)*actualSize),
+			desired.Slice(i*desiredSize, (i+1)*desiredSize))
+	}
+	return res
+}
+
+// Accuracy is an Evaluator which computes the fraction of
+// outputs whose largest component matches the largest
+// component of the desired output.
+//
+// It supports the same batches and networks as
+// MetricEvaluator.
+type Accuracy struct{}
+
+// Evaluate computes the classification accuracy.
+func (a *Accuracy) Evaluate(e Entity, b anysgd.Batch) float64 {
+	return a.metric().Evaluate(e, b)
+}
+
+// EvaluateErr is like Evaluate, but it returns an error
+// for unsupported entities or batches.
+func (a *Accuracy) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	return a.metric().EvaluateErr(e, b)
+}
+
+// SampleFitnesses produces 1 for every correctly
+// classified sample and 0 for the rest.
+func (a *Accuracy) SampleFitnesses(e Entity, b anysgd.Batch) []float64 {
+	return a.metric().SampleFitnesses(e, b)
+}
+
+// EvaluateSamples computes the classification accuracy
+// along with the per-sample fitnesses.
+func (a *Accuracy) EvaluateSamples(e Entity, b anysgd.Batch) (float64, []float64, error) {
+	return a.metric().EvaluateSamples(e, b)
+}
+
+func (a *Accuracy) metric() *MetricEvaluator {
+	return &MetricEvaluator{
+		Metric: func(actual, desired anyvec.Vector) float64 {
+			if anyvec.MaxIndex(actual) == anyvec.MaxIndex(desired) {
+				return 1
+			}
+			return 0
+		},
+	}
+}
+
+// WeightedSum is an Evaluator which combines the fitnesses
+// from several evaluators.
+type WeightedSum struct {
+	Evaluators []Evaluator
+
+	// Weights contains one weight per evaluator.
+	Weights []float64
+}
+
+// Evaluate computes the weighted sum of the fitnesses.
+func (w *WeightedSum) Evaluate(e Entity, b anysgd.Batch) float64 {
+	res, err := w.EvaluateErr(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr is like Evaluate, but it returns an error
+// if the weights do not match the evaluators, as well as
+// errors from evaluators which are FallibleEvaluators.
+func (w *WeightedSum) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	if err := w.checkWeights(); err != nil {
+		return 0, err
+	}
+	var res float64
+	for i, evaluator := range w.Evaluators {
+		fitness, err := evaluate(evaluator, e, b)
+		if err != nil {
+			return 0, err
+		}
+		res += w.Weights[i] * fitness
+	}
+	return res, nil
+}
+
+// SampleFitnesses computes the weighted sum of the
+// per-sample fitnesses.
+// Every evaluator must be a SampleEvaluator.
+func (w *WeightedSum) SampleFitnesses(e Entity, b anysgd.Batch) []float64 {
+	_, res, err := w.EvaluateSamples(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateSamples computes the weighted sums of the
+// fitnesses and of the per-sample fitnesses.
+// Every evaluator must be a SampleEvaluator.
+func (w *WeightedSum) EvaluateSamples(e Entity, b anysgd.Batch) (float64, []float64, error) {
+	if err := w.checkWeights(); err != nil {
+		return 0, nil, err
+	}
+	var fitness float64
+	var res []float64
+	for i, evaluator := range w.Evaluators {
+		fit, fits, err := evaluateSamples(evaluator, e, b)
+		if err != nil {
+			return 0, nil, err
+		}
+		fitness += w.Weights[i] * fit
+		if res == nil {
+			res = make([]float64, len(fits))
+		}
+		for j, x := range fits {
+			res[j] += w.Weights[i] * x
+		}
+	}
+	return fitness, res, nil
+}
+
+func (w *WeightedSum) checkWeights() error {
+	if len(w.Weights) != len(w.Evaluators) {
+		return fmt.Errorf("weighted sum: %d weights for %d evaluators", len(w.Weights),
+			len(w.Evaluators))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestAccuracy(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	fc := anynet.NewFCZero(c, 2, 2)
+	fc.Weights.Vector.SetData([]float64{1, 0, 0, 1})
+	entity := &NetEntity{Parameterizer: anynet.Net{fc, anynet.LogSoftmax}}
+	batch := &anyff.Batch{
+		Inputs: anydiff.NewConst(c.MakeVectorData([]float64{
+			1, 0, 0, 1, 2, 1,
+		})),
+		Outputs: anydiff.NewConst(c.MakeVectorData([]float64{
+			1, 0, 1, 0, 1, 0,
+		})),
+		Num: 3,
+	}
+
+	accuracy := (&Accuracy{}).Evaluate(entity, batch)
+	if math.Abs(accuracy-2.0/3) > 1e-8 {
+		t.Errorf("expected accuracy 2/3 but got %f", accuracy)
+	}
+	fits := (&Accuracy{}).SampleFitnesses(entity, batch)
+	for i, expected := range []float64{1, 0, 1} {
+		if fits[i] != expected {
+			t.Errorf("sample %d: expected %f but got %f", i, expected, fits[i])
+		}
+	}
+
+	cost := &NegCost{Cost: anynet.DotCost{}}
+	sum := &WeightedSum{
+		Evaluators: []Evaluator{cost, &Accuracy{}},
+		Weights:    []float64{1, 0.5},
+	}
+	expected := cost.Evaluate(entity, batch) + 0.5*accuracy
+	if actual := sum.Evaluate(entity, batch); math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected weighted sum %f but got %f", expected, actual)
+	}
+	sum.Weights = sum.Weights[:1]
+	if _, err := sum.EvaluateErr(entity, batch); err == nil {
+		t.Error("expected an error for mismatched weights")
+	}
+	if _, _, err := sum.EvaluateSamples(entity, batch); err == nil {
+		t.Error("expected an error for mismatched weights")
+	}
+}
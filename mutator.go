@@ -3,7 +3,6 @@ package leea
 import (
 	"math/rand"
 
-	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anyvec"
 )
 
@@ -17,18 +16,19 @@ type Mutator interface {
 }
 
 // An AddMutator adds random noise to the parameters of
-// entities which implement anynet.Parameterizer.
+// entities which implement anynet.Parameterizer, such as
+// NetEntity and VectorEntity.
 type AddMutator struct {
 	Stddev Schedule
 }
 
 // Mutate adds Gaussian mutations to the parameters.
-// The e argument must be an sgd.Learner.
+// The e argument must be an anynet.Parameterizer.
 func (n *AddMutator) Mutate(t int, e Entity, s rand.Source) {
-	parameterizer := e.(anynet.Parameterizer)
+	defer touchEntity(e)
 	r := rand.New(s)
 	d := n.Stddev.ValueAtTime(t)
-	for _, p := range parameterizer.Parameters() {
+	for _, p := range entityParameters(e) {
 		randVec := p.Vector.Creator().MakeVector(p.Vector.Len())
 		anyvec.Rand(randVec, anyvec.Normal, r)
 		randVec.Scale(randVec.Creator().MakeNumeric(d))
@@ -37,8 +37,8 @@ func (n *AddMutator) Mutate(t int, e Entity, s rand.Source) {
 }
 
 // A SetMutator randomly assigns a certain fraction of
-// the parameters of an sgd.Learner to values sampled
-// from NumSampler.
+// the parameters of an anynet.Parameterizer to values
+// sampled from a normal distribution.
 type SetMutator struct {
 	Fraction Schedule
 
@@ -48,12 +48,12 @@ type SetMutator struct {
 }
 
 // Mutate replaces some values with randomly-sampled ones.
-// The e argument must be an sgd.Learner.
+// The e argument must be an anynet.Parameterizer.
 func (s *SetMutator) Mutate(t int, e Entity, source rand.Source) {
-	parameterizer := e.(anynet.Parameterizer)
+	defer touchEntity(e)
 	r := rand.New(source)
 	frac := s.Fraction.ValueAtTime(t)
-	for pIdx, p := range parameterizer.Parameters() {
+	for pIdx, p := range entityParameters(e) {
 		stddev := s.Stddevs[pIdx]
 
 		randVec := p.Vector.Creator().MakeVector(p.Vector.Len())
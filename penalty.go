@@ -0,0 +1,167 @@
+package leea
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// A PenaltyEvaluator wraps an Evaluator and subtracts
+// complexity penalties computed from the parameters of
+// the entity, so that selection favors small or sparse
+// networks.
+//
+// Entities must implement anynet.Parameterizer.
+// Every penalty is disabled when its coefficient is 0.
+type PenaltyEvaluator struct {
+	Evaluator Evaluator
+
+	// L1 is the coefficient for the sum of the absolute
+	// values of the parameters.
+	L1 float64
+
+	// L2 is the coefficient for the sum of the squares of
+	// the parameters.
+	L2 float64
+
+	// MaxNorm is the coefficient for the largest absolute
+	// value of any parameter.
+	MaxNorm float64
+
+	// NonZero is the coefficient for the number of
+	// parameters whose absolute values exceed
+	// ZeroThreshold.
+	NonZero float64
+
+	// ZeroThreshold is the largest absolute value which
+	// is treated as zero by the NonZero penalty.
+	ZeroThreshold float64
+
+	// Normalize indicates that the L1, L2, and NonZero
+	// penalties should be divided by the total number of
+	// parameters.
+	Normalize bool
+}
+
+// Evaluate computes the penalized fitness.
+// It panics if the entity is unsupported.
+func (p *PenaltyEvaluator) Evaluate(e Entity, b anysgd.Batch) float64 {
+	res, err := p.EvaluateErr(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr is like Evaluate, but it returns an error
+// if the entity is unsupported, or if the wrapped
+// Evaluator is a FallibleEvaluator and fails.
+func (p *PenaltyEvaluator) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	penalty, err := p.PenaltyErr(e)
+	if err != nil {
+		return 0, err
+	}
+	res, err := evaluate(p.Evaluator, e, b)
+	if err != nil {
+		return 0, err
+	}
+	return res - penalty, nil
+}
+
+// SampleFitnesses subtracts the penalty from every
+// per-sample fitness.
+// The wrapped Evaluator must be a SampleEvaluator.
+func (p *PenaltyEvaluator) SampleFitnesses(e Entity, b anysgd.Batch) []float64 {
+	_, res, err := p.EvaluateSamples(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateSamples subtracts the penalty from the fitness
+// and from every per-sample fitness.
+// The wrapped Evaluator must be a SampleEvaluator.
+func (p *PenaltyEvaluator) EvaluateSamples(e Entity, b anysgd.Batch) (float64,
+	[]float64, error) {
+	penalty, err := p.PenaltyErr(e)
+	if err != nil {
+		return 0, nil, err
+	}
+	fitness, res, err := evaluateSamples(p.Evaluator, e, b)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range res {
+		res[i] -= penalty
+	}
+	return fitness - penalty, res, nil
+}
+
+// CanEvaluateAll returns true if the wrapped Evaluator is
+// a PopulationEvaluator which supports the entities and
+// the batch.
+func (p *PenaltyEvaluator) CanEvaluateAll(e []Entity, b anysgd.Batch) bool {
+	pe, ok := p.Evaluator.(PopulationEvaluator)
+	return ok && pe.CanEvaluateAll(e, b)
+}
+
+// EvaluateAll computes the penalized fitness of every
+// entity using the wrapped PopulationEvaluator.
+func (p *PenaltyEvaluator) EvaluateAll(e []Entity, b anysgd.Batch) []float64 {
+	res := p.Evaluator.(PopulationEvaluator).EvaluateAll(e, b)
+	for i, entity := range e {
+		res[i] -= p.Penalty(entity)
+	}
+	return res
+}
+
+// Penalty computes the total penalty for an entity.
+// It panics if the entity is unsupported.
+func (p *PenaltyEvaluator) Penalty(e Entity) float64 {
+	res, err := p.PenaltyErr(e)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// PenaltyErr is like Penalty, but it returns an error if
+// the entity is not an anynet.Parameterizer.
+func (p *PenaltyEvaluator) PenaltyErr(e Entity) (float64, error) {
+	params, ok := e.(anynet.Parameterizer)
+	if !ok {
+		return 0, fmt.Errorf("penalty: unsupported entity: %T", e)
+	}
+	var l1, l2, maxNorm, nonZero float64
+	var count int
+	for _, param := range params.Parameters() {
+		v := param.Vector
+		c := v.Creator()
+		count += v.Len()
+		if p.L1 != 0 {
+			l1 += c.Float64(anyvec.AbsSum(v))
+		}
+		if p.L2 != 0 {
+			l2 += c.Float64(v.Dot(v))
+		}
+		if p.MaxNorm != 0 && v.Len() > 0 {
+			maxNorm = math.Max(maxNorm, c.Float64(anyvec.AbsMax(v)))
+		}
+		if p.NonZero != 0 {
+			squares := v.Copy()
+			squares.Mul(v)
+			anyvec.GreaterThan(squares, c.MakeNumeric(p.ZeroThreshold*p.ZeroThreshold))
+			nonZero += c.Float64(anyvec.Sum(squares))
+		}
+	}
+	if p.Normalize && count > 0 {
+		l1 /= float64(count)
+		l2 /= float64(count)
+		nonZero /= float64(count)
+	}
+	return p.L1*l1 + p.L2*l2 + p.MaxNorm*maxNorm + p.NonZero*nonZero, nil
+}
@@ -0,0 +1,52 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestPenaltyEvaluator(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	fc := anynet.NewFCZero(c, 2, 2)
+	fc.Weights.Vector.SetData([]float64{1, -3, 0, 0.01})
+	fc.Biases.Vector.SetData([]float64{0.5, 0})
+	entity := &NetEntity{Parameterizer: fc}
+
+	tests := []struct {
+		Evaluator *PenaltyEvaluator
+		Expected  float64
+	}{
+		{&PenaltyEvaluator{L1: 2}, 2 * 4.51},
+		{&PenaltyEvaluator{L2: 1}, 1 + 9 + 0.0001 + 0.25},
+		{&PenaltyEvaluator{MaxNorm: 0.5}, 1.5},
+		{&PenaltyEvaluator{NonZero: 1}, 4},
+		{&PenaltyEvaluator{NonZero: 1, ZeroThreshold: 0.1}, 3},
+		{&PenaltyEvaluator{L1: 1, Normalize: true}, 4.51 / 6},
+	}
+	for i, test := range tests {
+		if actual := test.Evaluator.Penalty(entity); math.Abs(actual-test.Expected) > 1e-8 {
+			t.Errorf("test %d: expected %f but got %f", i, test.Expected, actual)
+		}
+	}
+}
+
+func TestPenaltyEvaluatorUnsupported(t *testing.T) {
+	eval := &PenaltyEvaluator{
+		Evaluator: &ObjectiveEvaluator{Objective: func(x []float64) float64 { return 0 }},
+		L1:        1,
+	}
+	if _, err := eval.EvaluateErr(unparameterized{}, nil); err == nil {
+		t.Error("expected an error from EvaluateErr")
+	}
+	if _, _, err := eval.EvaluateSamples(unparameterized{}, nil); err == nil {
+		t.Error("expected an error from EvaluateSamples")
+	}
+}
+
+type unparameterized struct{}
+
+func (u unparameterized) Decay(rate float64) {}
+func (u unparameterized) Set(e Entity)       {}
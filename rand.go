@@ -0,0 +1,57 @@
+package leea
+
+import "math/rand"
+
+// A RandSetter is a component, such as a Selector,
+// Crosser, Mater, or SampleSource, which can draw its
+// random numbers from a given generator.
+//
+// When Trainer.Rand is set, the Trainer passes it to its
+// components which implement RandSetter, so that training
+// is reproducible.
+type RandSetter interface {
+	// SetRand sets the generator to use.
+	// If r is nil, the global math/rand generator is used.
+	SetRand(r *rand.Rand)
+}
+
+// setRand calls SetRand if obj is a RandSetter.
+func setRand(obj interface{}, r *rand.Rand) {
+	if s, ok := obj.(RandSetter); ok {
+		s.SetRand(r)
+	}
+}
+
+// rng draws random numbers from a generator, or from the
+// global math/rand generator if the generator is nil.
+type rng struct {
+	Rand *rand.Rand
+}
+
+func (r rng) Int63() int64 {
+	if r.Rand == nil {
+		return rand.Int63()
+	}
+	return r.Rand.Int63()
+}
+
+func (r rng) Intn(n int) int {
+	if r.Rand == nil {
+		return rand.Intn(n)
+	}
+	return r.Rand.Intn(n)
+}
+
+func (r rng) Float64() float64 {
+	if r.Rand == nil {
+		return rand.Float64()
+	}
+	return r.Rand.Float64()
+}
+
+func (r rng) Perm(n int) []int {
+	if r.Rand == nil {
+		return rand.Perm(n)
+	}
+	return r.Rand.Perm(n)
+}
@@ -2,6 +2,7 @@ package leea
 
 import (
 	"errors"
+	"math/rand"
 
 	"github.com/unixpickle/anynet/anysgd"
 )
@@ -11,6 +12,94 @@ type SampleSource interface {
 	MiniBatch() (anysgd.SampleList, error)
 }
 
+// A BatchResizer is a SampleSource whose mini-batch size
+// can be changed between mini-batches.
+type BatchResizer interface {
+	SampleSource
+
+	SetBatchSize(n int)
+}
+
+// A ProgressSource is a SampleSource which adapts to the
+// progress of a Trainer.
+type ProgressSource interface {
+	SampleSource
+
+	// Progress is called before every generation, before
+	// any mini-batches are requested.
+	Progress(t *Trainer)
+}
+
+// A FeedbackSource is a SampleSource which adapts to how
+// the population performs on individual samples.
+type FeedbackSource interface {
+	SampleSource
+
+	// Feedback provides the mean fitness of the population
+	// on each sample of the most recent mini-batch, in the
+	// order the samples appeared in the mini-batch.
+	Feedback(fitnesses []float64)
+}
+
+// A WrapperSource is a SampleSource which draws its
+// mini-batches from another SampleSource.
+//
+// The Trainer looks through wrappers to find
+// BatchResizers, ProgressSources, and FeedbackSources, so
+// that a wrapper supports exactly the interfaces of the
+// source it wraps.
+type WrapperSource interface {
+	SampleSource
+
+	// Unwrap returns the wrapped SampleSource.
+	Unwrap() SampleSource
+}
+
+// batchResizer finds the first BatchResizer in a chain of
+// WrapperSources.
+func batchResizer(s SampleSource) (BatchResizer, bool) {
+	for {
+		if r, ok := s.(BatchResizer); ok {
+			return r, true
+		}
+		w, ok := s.(WrapperSource)
+		if !ok {
+			return nil, false
+		}
+		s = w.Unwrap()
+	}
+}
+
+// progressSource finds the first ProgressSource in a
+// chain of WrapperSources.
+func progressSource(s SampleSource) (ProgressSource, bool) {
+	for {
+		if p, ok := s.(ProgressSource); ok {
+			return p, true
+		}
+		w, ok := s.(WrapperSource)
+		if !ok {
+			return nil, false
+		}
+		s = w.Unwrap()
+	}
+}
+
+// feedbackSource finds the first FeedbackSource in a
+// chain of WrapperSources.
+func feedbackSource(s SampleSource) (FeedbackSource, bool) {
+	for {
+		if f, ok := s.(FeedbackSource); ok {
+			return f, true
+		}
+		w, ok := s.(WrapperSource)
+		if !ok {
+			return nil, false
+		}
+		s = w.Unwrap()
+	}
+}
+
 // A CycleSampleSource produces mini-batches by
 // shuffling and cycling through an anysgd.SampleList.
 type CycleSampleSource struct {
@@ -23,6 +112,13 @@ type CycleSampleSource struct {
 	BatchSize int
 
 	curIdx int
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (c *CycleSampleSource) SetRand(r *rand.Rand) {
+	c.rng.Rand = r
 }
 
 // MiniBatch produces the next batch of samples, shuffling
@@ -32,10 +128,79 @@ func (c *CycleSampleSource) MiniBatch() (anysgd.SampleList, error) {
 		return nil, errors.New("batch size exceeds sample count")
 	}
 	if c.curIdx == 0 || c.curIdx+c.BatchSize > c.Samples.Len() {
-		anysgd.Shuffle(c.Samples)
+		if c.rng.Rand == nil {
+			anysgd.Shuffle(c.Samples)
+		} else {
+			shuffle(c.Samples, c.rng)
+		}
 		c.curIdx = 0
 	}
 	subset := c.Samples.Slice(c.curIdx, c.curIdx+c.BatchSize)
 	c.curIdx += c.BatchSize
 	return subset, nil
 }
+
+// SetBatchSize sets c.BatchSize.
+func (c *CycleSampleSource) SetBatchSize(n int) {
+	c.BatchSize = n
+}
+
+// shuffle is like anysgd.Shuffle, but it draws from the
+// given generator.
+func shuffle(s anysgd.SampleList, r rng) {
+	for i := 0; i < s.Len(); i++ {
+		s.Swap(i, i+r.Intn(s.Len()-i))
+	}
+	if p, ok := s.(anysgd.PostShuffler); ok {
+		p.PostShuffle()
+	}
+}
+
+// An indexedList tracks the positions of samples in a
+// SampleList as they are swapped around, making it
+// possible to gather arbitrary samples into a batch.
+type indexedList struct {
+	Samples anysgd.SampleList
+
+	// order[i] is the original index of the sample at
+	// index i, and pos is the inverse of order.
+	order []int
+	pos   []int
+}
+
+func newIndexedList(s anysgd.SampleList) *indexedList {
+	res := &indexedList{
+		Samples: s,
+		order:   make([]int, s.Len()),
+		pos:     make([]int, s.Len()),
+	}
+	for i := range res.order {
+		res.order[i] = i
+		res.pos[i] = i
+	}
+	return res
+}
+
+// moveTo swaps the sample with the given original index
+// into the given position of the list.
+func (i *indexedList) moveTo(orig, dest int) {
+	src := i.pos[orig]
+	if src == dest {
+		return
+	}
+	i.Samples.Swap(src, dest)
+	other := i.order[dest]
+	i.order[dest], i.order[src] = orig, other
+	i.pos[orig], i.pos[other] = dest, src
+}
+
+// gather moves the samples with the given original
+// indices to the start of the list and returns them as a
+// slice.
+// The original indices must be distinct.
+func (i *indexedList) gather(origs []int) anysgd.SampleList {
+	for dest, orig := range origs {
+		i.moveTo(orig, dest)
+	}
+	return i.Samples.Slice(0, len(origs))
+}
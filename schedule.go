@@ -22,6 +22,29 @@ func (e *ExpSchedule) ValueAtTime(t int) float64 {
 	return e.Baseline + e.Init*math.Pow(e.DecayRate, float64(t))
 }
 
+// A StepSchedule is a piecewise-constant schedule.
+type StepSchedule struct {
+	// Times contains the timesteps at which the value
+	// changes, in ascending order.
+	Times []int
+
+	// Values contains one more value than Times.
+	// Values[0] is used before Times[0], Values[1] is used
+	// from Times[0] until Times[1], etc.
+	Values []float64
+}
+
+// ValueAtTime returns the value for the step containing
+// timestep t.
+func (s *StepSchedule) ValueAtTime(t int) float64 {
+	for i, x := range s.Times {
+		if t < x {
+			return s.Values[i]
+		}
+	}
+	return s.Values[len(s.Times)]
+}
+
 // A DecaySchedule tunes the decay parameter based on the
 // mutation standard deviation in order to target a weight
 // standard deviation.
@@ -10,6 +10,103 @@ import (
 type FitEntity struct {
 	Entity  Entity
 	Fitness float64
+
+	// Scale is the number by which Fitness should be
+	// divided to get the running average fitness.
+	// It follows the same decay and blending as Fitness,
+	// so it accounts for the individual's actual history
+	// of evaluations.
+	//
+	// A Scale of 0 indicates that Fitness is already
+	// normalized.
+	Scale float64
+
+	// SqFitness is like Fitness, but it accumulates the
+	// squares of evaluations.
+	// It is used to compute the variance of the fitness.
+	SqFitness float64
+
+	// Age is the number of generations this individual's
+	// genetic material has been evolving.
+	Age int
+
+	// Evals is the number of times this individual (or
+	// its ancestors) have been evaluated.
+	Evals int
+}
+
+// RunningFitness returns the running average fitness of
+// the entity.
+func (f *FitEntity) RunningFitness() float64 {
+	if f.Scale == 0 {
+		return f.Fitness
+	}
+	return f.Fitness / f.Scale
+}
+
+// FitnessVariance returns the running variance of the
+// individual's evaluations.
+func (f *FitEntity) FitnessVariance() float64 {
+	if f.Scale == 0 {
+		return 0
+	}
+	mean := f.Fitness / f.Scale
+	return math.Max(0, f.SqFitness/f.Scale-mean*mean)
+}
+
+// LowerBound computes a lower confidence bound on the
+// individual's true fitness by subtracting the given
+// number of standard errors from the running fitness.
+//
+// Evals is used as the number of evaluations.
+// If the individual has fewer than two evaluations or no
+// measured variance, priorVariance is used in place of
+// its own variance, so that individuals with few
+// evaluations are not trusted more than well-measured
+// ones.
+func (f *FitEntity) LowerBound(stdErrs, priorVariance float64) float64 {
+	variance := f.FitnessVariance()
+	if f.Evals < 2 || variance == 0 {
+		variance = priorVariance
+	}
+	n := math.Max(1, float64(f.Evals))
+	return f.RunningFitness() - stdErrs*math.Sqrt(variance/n)
+}
+
+// addEvaluation decays the accumulated fitness and adds
+// the mean of the scores.
+func (f *FitEntity) addEvaluation(inheritance float64, scores []float64) {
+	var sum, sqSum float64
+	for _, x := range scores {
+		sum += x
+		sqSum += x * x
+	}
+	n := float64(len(scores))
+	f.Fitness = f.Fitness*inheritance + sum/n
+	f.SqFitness = f.SqFitness*inheritance + sqSum/n
+	f.Scale = f.Scale*inheritance + 1
+	f.Evals += len(scores)
+}
+
+// set copies the entity and all of its statistics from
+// f1 into f.
+func (f *FitEntity) set(f1 *FitEntity) {
+	f.Entity.Set(f1.Entity)
+	f.Fitness = f1.Fitness
+	f.Scale = f1.Scale
+	f.SqFitness = f1.SqFitness
+	f.Age = f1.Age
+	f.Evals = f1.Evals
+}
+
+// reset sets all the statistics of f to their initial
+// values, as if f were a new individual.
+func (f *FitEntity) reset() {
+	f.Fitness = 0
+	f.Scale = 0
+	f.SqFitness = 0
+	f.Age = 0
+	f.Evals = 0
 }
 
 // A Selector chooses individuals based on their
@@ -21,8 +118,10 @@ type Selector interface {
 	// The receiver should not modify the slice or assume
 	// that the slice will remain unchanged after the call.
 	//
-	// The scale indicates the value by which fitnesses
-	// should be divided before being used.
+	// Fitnesses should be read through RunningFitness,
+	// which normalizes each entity individually.
+	// The scale indicates an additional value by which
+	// these fitnesses should be divided before being used.
 	SetEntities(e []*FitEntity, scale float64)
 
 	// Select selects the next entity.
@@ -51,6 +150,13 @@ type RouletteWheel struct {
 	entities []*FitEntity
 	scale    float64
 	total    float64
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (r *RouletteWheel) SetRand(gen *rand.Rand) {
+	r.rng.Rand = gen
 }
 
 // SetEntities sets the entities for selection.
@@ -62,12 +168,12 @@ func (r *RouletteWheel) SetEntities(e []*FitEntity, scale float64) {
 
 // Select selects an entity and removes it from the pool.
 func (r *RouletteWheel) Select() *FitEntity {
-	num := rand.Float64() * r.total
+	num := r.rng.Float64() * r.total
 	for i, e := range r.entities {
-		num -= r.properFitness(e.Fitness)
+		num -= r.properFitness(e.RunningFitness())
 		if i == len(r.entities)-1 || num < 0 {
 			oldTotal := r.total
-			r.total -= r.properFitness(e.Fitness)
+			r.total -= r.properFitness(e.RunningFitness())
 
 			// Recompute if too much numerical precision was lost.
 			if math.Abs(r.total/oldTotal) < 1e-3 {
@@ -95,7 +201,7 @@ func (r *RouletteWheel) properFitness(x float64) float64 {
 func (r *RouletteWheel) recomputeTotal() {
 	r.total = 0
 	for _, e := range r.entities {
-		r.total += r.properFitness(e.Fitness)
+		r.total += r.properFitness(e.RunningFitness())
 	}
 }
 
@@ -135,6 +241,13 @@ type TournamentSelector struct {
 	Prob float64
 
 	entities []*FitEntity
+
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (t *TournamentSelector) SetRand(r *rand.Rand) {
+	t.rng.Rand = r
 }
 
 // SetEntities sets the entities for selection.
@@ -148,7 +261,7 @@ func (t *TournamentSelector) Select() *FitEntity {
 	prob := t.Prob
 	var chosen *FitEntity
 	for i, entry := range pool {
-		if rand.Float64() < prob || i == len(pool)-1 {
+		if t.rng.Float64() < prob || i == len(pool)-1 {
 			chosen = entry
 			break
 		}
@@ -170,7 +283,7 @@ func (t *TournamentSelector) tournamentPool() []*FitEntity {
 	if len(t.entities) < t.Size {
 		s = append(s, t.entities...)
 	} else {
-		indices := rand.Perm(len(t.entities))
+		indices := t.rng.Perm(len(t.entities))
 		for _, j := range indices[:t.Size] {
 			s = append(s, t.entities[j])
 		}
@@ -190,5 +303,16 @@ func (f fitnessSorter) Swap(i, j int) {
 }
 
 func (f fitnessSorter) Less(i, j int) bool {
-	return f[i].Fitness > f[j].Fitness
+	return f[i].RunningFitness() > f[j].RunningFitness()
+}
+
+type boundSorter struct {
+	fitnessSorter
+	Confidence float64
+	Prior      float64
+}
+
+func (b *boundSorter) Less(i, j int) bool {
+	return b.fitnessSorter[i].LowerBound(b.Confidence, b.Prior) >
+		b.fitnessSorter[j].LowerBound(b.Confidence, b.Prior)
 }
@@ -23,3 +23,27 @@ func TestRouletteWheel(t *testing.T) {
 		}
 	}
 }
+
+func TestFitEntityVariance(t *testing.T) {
+	e := &FitEntity{}
+	for _, x := range []float64{1, 3, 1, 3} {
+		e.addEvaluation(1, []float64{x})
+	}
+	if e.RunningFitness() != 2 {
+		t.Errorf("expected mean 2 but got %f", e.RunningFitness())
+	}
+	if e.FitnessVariance() != 1 {
+		t.Errorf("expected variance 1 but got %f", e.FitnessVariance())
+	}
+	if e.LowerBound(2, 9) != 1 {
+		t.Errorf("expected lower bound 1 but got %f", e.LowerBound(2, 9))
+	}
+
+	// A single evaluation has no measured variance, so the
+	// prior variance is used.
+	e = &FitEntity{}
+	e.addEvaluation(1, []float64{2})
+	if e.LowerBound(2, 9) != -4 {
+		t.Errorf("expected lower bound -4 but got %f", e.LowerBound(2, 9))
+	}
+}
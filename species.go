@@ -0,0 +1,378 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Speciation implements NEAT-style species management.
+//
+// Every generation, each individual joins the first
+// species whose representative is within Threshold of
+// it, or else founds a new species.
+// Fitnesses are shared within species, and every species
+// gets a number of offspring proportional to its mean
+// fitness, so that new species are protected from
+// competition with established ones.
+// Individuals only compete and mate within their own
+// species.
+//
+// Entities must implement Copier so that species can keep
+// representatives.
+type Speciation struct {
+	// Threshold is the compatibility distance below which
+	// an individual joins a species.
+	Threshold float64
+
+	// Distance computes the compatibility distance between
+	// two entities.
+	// If nil, the Euclidean distance between parameters is
+	// used, which requires anynet.Parameterizer entities.
+	Distance func(e1, e2 Entity) float64
+
+	// StagnationLimit is the number of generations a
+	// species may go without improving its best fitness
+	// before it is removed.
+	// The species with the best individual is never
+	// removed.
+	// If 0, species are never removed for stagnation.
+	StagnationLimit int
+
+	// Elitism is the number of top individuals in every
+	// species which are untouched by mutation and
+	// cross-over.
+	Elitism int
+
+	// Observer, if non-nil, is called with the statistics
+	// of every species after offspring are allocated.
+	Observer func(stats []*SpeciesStats)
+
+	species []*species
+	nextID  int
+	members map[*FitEntity]int
+}
+
+// SpeciesStats summarizes a species for one generation.
+type SpeciesStats struct {
+	ID int
+
+	// Size is the number of members before reproduction.
+	Size int
+
+	// Offspring is the number of members after
+	// reproduction.
+	Offspring int
+
+	MaxFitness  float64
+	MeanFitness float64
+
+	// SharedFitness is the mean fitness of the species
+	// after fitness sharing, on which the offspring
+	// allocation is based.
+	SharedFitness float64
+
+	// Age is the number of generations since the species
+	// was founded.
+	Age int
+
+	// Stagnation is the number of generations since the
+	// best fitness of the species improved.
+	Stagnation int
+
+	// Removed indicates that the species was removed for
+	// stagnation.
+	Removed bool
+}
+
+type species struct {
+	ID             int
+	Representative Entity
+	Members        []*FitEntity
+	Founded        int
+	BestFitness    float64
+	LastImproved   int
+}
+
+// SpeciesOf returns the species ID of an individual, or -1
+// if the individual has not been assigned a species.
+//
+// This can be used as the Group of a GroupMater.
+func (s *Speciation) SpeciesOf(e *FitEntity) int {
+	if id, ok := s.members[e]; ok {
+		return id
+	}
+	return -1
+}
+
+// reproduce assigns species, performs selection within
+// each species, and returns the species as contiguous
+// groups of the population.
+// The best individual of every species is first in its
+// group.
+func (s *Speciation) reproduce(t *Trainer) ([][]*FitEntity, error) {
+	if err := s.speciate(t); err != nil {
+		return nil, err
+	}
+	stats := s.stats(t)
+	s.removeStagnant(t, stats)
+	s.allocate(len(t.Population), stats)
+
+	var groups [][]*FitEntity
+	var free []*FitEntity
+	for i, spec := range s.species {
+		count := stats[i].Offspring
+		members := s.selectMembers(t, spec.Members)
+		if count < len(members) {
+			free = append(free, members[count:]...)
+			members = members[:count]
+		}
+		groups = append(groups, members)
+	}
+	for i, spec := range s.species {
+		group := groups[i]
+		survivors := group[:s.survivorCount(t, len(spec.Members), len(group))]
+		for len(group) < stats[i].Offspring {
+			group = append(group, free[0])
+			free = free[1:]
+		}
+		for _, e := range group[len(survivors):] {
+			e.set(survivors[t.rng().Intn(len(survivors))])
+		}
+		for _, e := range group {
+			s.members[e] = spec.ID
+		}
+		spec.Members = group
+		groups[i] = group
+	}
+
+	var pop []*FitEntity
+	for _, group := range groups {
+		pop = append(pop, group...)
+	}
+	copy(t.Population, pop)
+	offset := 0
+	for i, group := range groups {
+		groups[i] = t.Population[offset : offset+len(group)]
+		offset += len(group)
+	}
+
+	if s.Observer != nil {
+		s.Observer(stats)
+	}
+	s.dropEmpty()
+	return groups, nil
+}
+
+// mutationTargets returns the individuals which are not
+// species elites.
+func (s *Speciation) mutationTargets(groups [][]*FitEntity) []*FitEntity {
+	var res []*FitEntity
+	for _, group := range groups {
+		if len(group) > s.Elitism {
+			res = append(res, group[s.Elitism:]...)
+		}
+	}
+	return res
+}
+
+// speciate assigns every individual to a species and
+// picks new representatives.
+func (s *Speciation) speciate(t *Trainer) error {
+	if s.members == nil {
+		s.members = map[*FitEntity]int{}
+	}
+	for _, spec := range s.species {
+		spec.Members = nil
+	}
+	for _, e := range t.Population {
+		var found *species
+		for _, spec := range s.species {
+			if s.distance(e.Entity, spec.Representative) < s.Threshold {
+				found = spec
+				break
+			}
+		}
+		if found == nil {
+			rep, err := copyEntity(e.Entity)
+			if err != nil {
+				return err
+			}
+			found = &species{
+				ID:             s.nextID,
+				Representative: rep,
+				Founded:        t.Generation,
+				BestFitness:    math.Inf(-1),
+				LastImproved:   t.Generation,
+			}
+			s.nextID++
+			s.species = append(s.species, found)
+		}
+		found.Members = append(found.Members, e)
+		s.members[e] = found.ID
+	}
+	s.dropEmpty()
+
+	for _, spec := range s.species {
+		member := spec.Members[t.rng().Intn(len(spec.Members))]
+		rep, err := copyEntity(member.Entity)
+		if err != nil {
+			return err
+		}
+		spec.Representative = rep
+	}
+	return nil
+}
+
+// stats computes species statistics with shared
+// fitnesses.
+//
+// Fitnesses are shifted to be non-negative before
+// sharing, so that proportional allocation makes sense
+// for any fitness measure.
+func (s *Speciation) stats(t *Trainer) []*SpeciesStats {
+	minFitness := math.Inf(1)
+	for _, e := range t.Population {
+		minFitness = math.Min(minFitness, e.RunningFitness())
+	}
+	var res []*SpeciesStats
+	for _, spec := range s.species {
+		st := &SpeciesStats{
+			ID:         spec.ID,
+			Size:       len(spec.Members),
+			MaxFitness: math.Inf(-1),
+			Age:        t.Generation - spec.Founded,
+		}
+		for _, e := range spec.Members {
+			fitness := e.RunningFitness()
+			st.MaxFitness = math.Max(st.MaxFitness, fitness)
+			st.MeanFitness += fitness / float64(len(spec.Members))
+			st.SharedFitness += (fitness - minFitness) / float64(len(spec.Members))
+		}
+		if st.MaxFitness > spec.BestFitness {
+			spec.BestFitness = st.MaxFitness
+			spec.LastImproved = t.Generation
+		}
+		st.Stagnation = t.Generation - spec.LastImproved
+		res = append(res, st)
+	}
+	return res
+}
+
+// removeStagnant marks stagnant species as removed,
+// always keeping the species with the best individual.
+func (s *Speciation) removeStagnant(t *Trainer, stats []*SpeciesStats) {
+	if s.StagnationLimit == 0 {
+		return
+	}
+	best := 0
+	for i, st := range stats {
+		if st.MaxFitness > stats[best].MaxFitness {
+			best = i
+		}
+	}
+	for i, st := range stats {
+		if i != best && st.Stagnation >= s.StagnationLimit {
+			st.Removed = true
+		}
+	}
+}
+
+// allocate sets the number of offspring for every species
+// in proportion to its shared fitness, using the largest
+// remainder method so that the total is popSize.
+func (s *Speciation) allocate(popSize int, stats []*SpeciesStats) {
+	var total float64
+	var live []*SpeciesStats
+	for _, st := range stats {
+		if !st.Removed {
+			live = append(live, st)
+			total += st.SharedFitness
+		}
+	}
+	weight := func(st *SpeciesStats) float64 {
+		if total == 0 {
+			return 1 / float64(len(live))
+		}
+		return st.SharedFitness / total
+	}
+
+	remaining := popSize
+	remainders := make([]float64, len(live))
+	for i, st := range live {
+		exact := weight(st) * float64(popSize)
+		st.Offspring = int(exact)
+		remainders[i] = exact - float64(st.Offspring)
+		remaining -= st.Offspring
+	}
+	order := make([]int, len(live))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := 0; i < remaining; i++ {
+		live[order[i%len(order)]].Offspring++
+	}
+}
+
+// selectMembers orders the members of a species, putting
+// the best Elitism members (or at least the best member)
+// first in order of fitness, followed by the rest as
+// ordered by the Trainer's Selector.
+func (s *Speciation) selectMembers(t *Trainer, members []*FitEntity) []*FitEntity {
+	res := append([]*FitEntity{}, members...)
+	sort.Sort(fitnessSorter(res))
+	elite := s.Elitism
+	if elite < 1 {
+		elite = 1
+	}
+	if elite > len(res) {
+		elite = len(res)
+	}
+	t.Selector.SetEntities(res[elite:], 1)
+	for i := elite; i < len(res); i++ {
+		res[i] = t.Selector.Select()
+	}
+	return res
+}
+
+// survivorCount computes the number of survivors for a
+// species of the given size, which is keeping the given
+// number of its members.
+func (s *Speciation) survivorCount(t *Trainer, size, kept int) int {
+	n := t.survivorCountFor(size)
+	if s.Elitism > n {
+		n = s.Elitism
+	}
+	if n > kept {
+		n = kept
+	}
+	return n
+}
+
+func (s *Speciation) dropEmpty() {
+	var res []*species
+	for _, spec := range s.species {
+		if len(spec.Members) > 0 {
+			res = append(res, spec)
+		}
+	}
+	s.species = res
+}
+
+func (s *Speciation) distance(e1, e2 Entity) float64 {
+	if s.Distance != nil {
+		return s.Distance(e1, e2)
+	}
+	return parameterDistance(e1, e2)
+}
+
+func copyEntity(e Entity) (Entity, error) {
+	c, ok := e.(Copier)
+	if !ok {
+		return nil, errors.New("speciation: entity does not implement Copier")
+	}
+	return c.Copy()
+}
@@ -0,0 +1,163 @@
+package leea
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSpeciationReproduce(t *testing.T) {
+	trainer := &Trainer{
+		Selector:      &SortSelector{},
+		SurvivalRatio: 0.5,
+	}
+	for i := 0; i < 20; i++ {
+		center, fitness := 0.0, 3.0
+		if i >= 5 {
+			center, fitness = 100, 1+float64(i%2)
+		}
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity:  NewVectorEntity([]float64{center + rand.Float64(), center}),
+			Fitness: fitness,
+		})
+	}
+
+	var stats []*SpeciesStats
+	spec := &Speciation{
+		Threshold: 10,
+		Observer:  func(s []*SpeciesStats) { stats = s },
+	}
+	groups, err := spec.reproduce(trainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 species but got %d", len(stats))
+	}
+
+	// Shared fitnesses are 2 and 8/15 after shifting.
+	if stats[0].Size != 5 || stats[1].Size != 15 || stats[0].Offspring != 16 ||
+		stats[1].Offspring != 4 {
+		t.Errorf("unexpected allocation: %+v %+v", *stats[0], *stats[1])
+	}
+	for i, st := range stats {
+		if len(groups[i]) != st.Offspring {
+			t.Errorf("group size %d but %d offspring", len(groups[i]), st.Offspring)
+		}
+		for _, e := range groups[i] {
+			if spec.SpeciesOf(e) != st.ID {
+				t.Error("wrong species for member")
+			}
+		}
+	}
+	if groups[1][0].Fitness != 2 {
+		t.Error("best member should come first")
+	}
+	for _, e := range groups[0] {
+		if e.Entity.(*VectorEntity).Floats()[1] != 0 {
+			t.Fatal("offspring were not copied from their own species")
+		}
+	}
+}
+
+func TestSpeciationElitism(t *testing.T) {
+	trainer := &Trainer{Selector: &TournamentSelector{Size: 1, Prob: 1}}
+	spec := &Speciation{Elitism: 3}
+	for trial := 0; trial < 20; trial++ {
+		var members []*FitEntity
+		for _, i := range rand.Perm(10) {
+			members = append(members, &FitEntity{Fitness: float64(i)})
+		}
+		res := spec.selectMembers(trainer, members)
+		for i, expected := range []float64{9, 8, 7} {
+			if res[i].Fitness != expected {
+				t.Fatalf("elite %d: expected fitness %f but got %f", i, expected,
+					res[i].Fitness)
+			}
+		}
+		seen := map[*FitEntity]bool{}
+		for _, e := range res {
+			seen[e] = true
+		}
+		if len(res) != 10 || len(seen) != 10 {
+			t.Fatal("selection should be a permutation of the members")
+		}
+	}
+}
+
+func TestSpeciationStagnation(t *testing.T) {
+	trainer := &Trainer{Selector: &SortSelector{}}
+	for i := 0; i < 10; i++ {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity([]float64{float64(100 * (i % 2))}),
+		})
+	}
+	var stats []*SpeciesStats
+	spec := &Speciation{
+		Threshold:       10,
+		StagnationLimit: 2,
+		Observer:        func(s []*SpeciesStats) { stats = s },
+	}
+
+	// The first species never improves, while the second
+	// improves every generation.
+	for gen := 0; gen < 3; gen++ {
+		trainer.Generation = gen
+		var count int
+		for _, e := range trainer.Population {
+			if e.Entity.(*VectorEntity).Floats()[0] > 50 {
+				e.Fitness = float64(gen + 1)
+			} else {
+				e.Fitness = float64(count % 2)
+				count++
+			}
+		}
+		if _, err := spec.reproduce(trainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(stats) != 2 || !stats[0].Removed || stats[0].Offspring != 0 ||
+		stats[1].Offspring != 10 {
+		t.Errorf("expected the first species to be removed: %+v", stats)
+	}
+}
+
+func TestSpeciationTrainer(t *testing.T) {
+	sphere := func(x []float64) float64 {
+		var res float64
+		for _, c := range x {
+			res += c * c
+		}
+		return res
+	}
+	trainer := &Trainer{
+		Evaluator:         &ObjectiveEvaluator{Objective: sphere, Minimize: true},
+		Selector:          &TournamentSelector{Size: 3, Prob: 1},
+		Mutator:           &AddMutator{Stddev: &ExpSchedule{Init: 0.5, DecayRate: 0.95}},
+		Crosser:           &UniformCrosser{},
+		CrossOverSchedule: &ExpSchedule{Baseline: 0.5},
+		SurvivalRatio:     0.3,
+		Speciation: &Speciation{
+			Threshold:       3,
+			StagnationLimit: 15,
+			Elitism:         1,
+		},
+	}
+	for i := 0; i < 40; i++ {
+		x := make([]float64, 3)
+		for j := range x {
+			x[j] = rand.NormFloat64() * 3
+		}
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity(x),
+		})
+	}
+	for i := 0; i < 60; i++ {
+		if err := trainer.generation(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	best := trainer.BestEntity().Entity.(*VectorEntity).Floats()
+	if value := sphere(best); value > 0.5 {
+		t.Errorf("objective did not get small enough: %f", value)
+	}
+}
@@ -0,0 +1,199 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// A StratifiedSampleSource produces mini-batches in which
+// every class is represented in a fixed proportion.
+//
+// By default, classes are represented in proportion to
+// their frequency in the sample list.
+// Weights can be used to override these proportions, for
+// example to produce class-balanced mini-batches.
+//
+// Like CycleSampleSource, it reorders the sample list,
+// so the batches it returns are only valid until the
+// next call to MiniBatch.
+type StratifiedSampleSource struct {
+	// Samples contains the samples to draw from.
+	Samples anysgd.SampleList
+
+	// Label returns the class of the sample at the given
+	// index in a list.
+	// It is called once per sample, on the first call to
+	// MiniBatch.
+	Label func(s anysgd.SampleList, idx int) int
+
+	// BatchSize indicates the number of samples to return
+	// from MiniBatch().
+	BatchSize int
+
+	// Weights, if non-nil, specifies the relative share of
+	// each mini-batch given to each class.
+	// Classes without a weight are never sampled.
+	Weights map[int]float64
+
+	// Histogram counts the classes in the most recent
+	// mini-batch.
+	Histogram map[int]int
+
+	classes []int
+	queues  map[int][]int
+	used    map[int]int
+	credit  map[int]float64
+	list    *indexedList
+}
+
+// MiniBatch produces the next stratified batch.
+func (s *StratifiedSampleSource) MiniBatch() (anysgd.SampleList, error) {
+	if s.BatchSize > s.Samples.Len() {
+		return nil, errors.New("batch size exceeds sample count")
+	}
+	if s.queues == nil {
+		s.init()
+	}
+
+	shares := s.shares()
+	if len(shares) == 0 {
+		return nil, errors.New("no classes to sample")
+	}
+	counts, err := s.allocate(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Histogram = map[int]int{}
+	var batch []int
+	for _, class := range s.classes {
+		inBatch := map[int]bool{}
+		for i := 0; i < counts[class]; i++ {
+			next := s.nextInClass(class)
+			for inBatch[next] {
+				// The class was reshuffled in the middle of
+				// the batch and we hit a duplicate.
+				next = s.nextInClass(class)
+			}
+			inBatch[next] = true
+			batch = append(batch, next)
+		}
+		if counts[class] > 0 {
+			s.Histogram[class] = counts[class]
+		}
+	}
+
+	return s.list.gather(batch), nil
+}
+
+// SetBatchSize sets s.BatchSize.
+func (s *StratifiedSampleSource) SetBatchSize(n int) {
+	s.BatchSize = n
+}
+
+func (s *StratifiedSampleSource) init() {
+	s.queues = map[int][]int{}
+	s.used = map[int]int{}
+	s.credit = map[int]float64{}
+	s.list = newIndexedList(s.Samples)
+	for i := 0; i < s.Samples.Len(); i++ {
+		class := s.Label(s.Samples, i)
+		if _, ok := s.queues[class]; !ok {
+			s.classes = append(s.classes, class)
+		}
+		s.queues[class] = append(s.queues[class], i)
+	}
+	sort.Ints(s.classes)
+	for _, class := range s.classes {
+		s.shuffleClass(class)
+	}
+}
+
+// shares computes the normalized fraction of each batch
+// given to each class.
+func (s *StratifiedSampleSource) shares() map[int]float64 {
+	res := map[int]float64{}
+	var total float64
+	for _, class := range s.classes {
+		w := float64(len(s.queues[class]))
+		if s.Weights != nil {
+			w = s.Weights[class]
+		}
+		if w > 0 {
+			res[class] = w
+			total += w
+		}
+	}
+	for class := range res {
+		res[class] /= total
+	}
+	return res
+}
+
+// allocate turns shares into exact counts, carrying the
+// rounding error over to future batches so that the
+// long-run proportions are exact.
+func (s *StratifiedSampleSource) allocate(shares map[int]float64) (map[int]int,
+	error) {
+	counts := map[int]int{}
+	remaining := s.BatchSize
+	for _, class := range s.classes {
+		s.credit[class] += shares[class] * float64(s.BatchSize)
+		n := int(math.Floor(s.credit[class]))
+		if n > remaining {
+			n = remaining
+		}
+		if n > len(s.queues[class]) {
+			n = len(s.queues[class])
+		}
+		counts[class] = n
+		remaining -= n
+	}
+	for remaining > 0 {
+		best := -1
+		var bestResidual float64
+		for _, class := range s.classes {
+			if shares[class] == 0 || counts[class] == len(s.queues[class]) {
+				continue
+			}
+			residual := s.credit[class] - float64(counts[class])
+			if best == -1 || residual > bestResidual {
+				best = class
+				bestResidual = residual
+			}
+		}
+		if best == -1 {
+			return nil, errors.New("batch size exceeds weighted sample count")
+		}
+		counts[best]++
+		remaining--
+	}
+	for class, n := range counts {
+		s.credit[class] -= float64(n)
+	}
+	return counts, nil
+}
+
+// nextInClass returns the original index of the next
+// sample for the class, reshuffling the class as needed.
+func (s *StratifiedSampleSource) nextInClass(class int) int {
+	if s.used[class] == len(s.queues[class]) {
+		s.shuffleClass(class)
+	}
+	res := s.queues[class][s.used[class]]
+	s.used[class]++
+	return res
+}
+
+func (s *StratifiedSampleSource) shuffleClass(class int) {
+	q := s.queues[class]
+	for i := len(q) - 1; i > 0; i-- {
+		j := rand.Intn(i + 1)
+		q[i], q[j] = q[j], q[i]
+	}
+	s.used[class] = 0
+}
@@ -0,0 +1,75 @@
+package leea
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestStratifiedSampleSource(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	var samples anyff.SliceSampleList
+	for i := 0; i < 100; i++ {
+		// Classes 0, 1, and 2 have 70, 20, and 10 samples.
+		class := 0
+		if i >= 90 {
+			class = 2
+		} else if i >= 70 {
+			class = 1
+		}
+		samples = append(samples, &anyff.Sample{
+			Input:  c.MakeVectorData([]float64{float64(i)}),
+			Output: c.MakeVectorData([]float64{float64(class)}),
+		})
+	}
+	label := func(s anysgd.SampleList, idx int) int {
+		return int(anyvec.Sum(s.(anyff.SliceSampleList)[idx].Output).(float64))
+	}
+
+	source := &StratifiedSampleSource{
+		Samples:   samples,
+		Label:     label,
+		BatchSize: 10,
+	}
+	for i := 0; i < 20; i++ {
+		batch, err := source.MiniBatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[int]int{}
+		seen := map[float64]bool{}
+		for j := 0; j < batch.Len(); j++ {
+			counts[label(batch, j)]++
+			id := anyvec.Sum(batch.(anyff.SliceSampleList)[j].Input).(float64)
+			if seen[id] {
+				t.Fatal("duplicate sample in batch")
+			}
+			seen[id] = true
+		}
+		if counts[0] != 7 || counts[1] != 2 || counts[2] != 1 {
+			t.Fatalf("unexpected class counts: %v", counts)
+		}
+		for class, n := range counts {
+			if source.Histogram[class] != n {
+				t.Fatalf("histogram %v does not match %v", source.Histogram, counts)
+			}
+		}
+	}
+
+	source.Weights = map[int]float64{0: 1, 1: 1, 2: 1}
+	source.BatchSize = 30
+	batch, err := source.MiniBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[int]int{}
+	for j := 0; j < batch.Len(); j++ {
+		counts[label(batch, j)]++
+	}
+	if counts[0] != 10 || counts[1] != 10 || counts[2] != 10 {
+		t.Errorf("unexpected balanced counts: %v", counts)
+	}
+}
@@ -0,0 +1,290 @@
+package leea
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultPrefetch is the default number of records which
+// a StreamSampleSource reads ahead.
+const DefaultPrefetch = 1024
+
+// A StreamPosition identifies a record in the files of a
+// StreamSampleSource.
+type StreamPosition struct {
+	// Epoch is the number of complete passes which have
+	// been made over the files.
+	Epoch int
+
+	// File is the index of the file in the path list.
+	File int
+
+	// Offset is the byte offset of the record in the file.
+	Offset int64
+}
+
+// Less returns true if s comes before s1.
+func (s StreamPosition) Less(s1 StreamPosition) bool {
+	if s.Epoch != s1.Epoch {
+		return s.Epoch < s1.Epoch
+	} else if s.File != s1.File {
+		return s.File < s1.File
+	}
+	return s.Offset < s1.Offset
+}
+
+// A StreamSampleSource streams records from a list of
+// files, cycling through the files forever.
+//
+// Records are read in the background, so that I/O can be
+// overlapped with evaluation.
+// A shuffle buffer is used to decorrelate mini-batches
+// without loading the whole data set into memory.
+//
+// Records can either be lines of text or binary records,
+// each prefixed by a little-endian uint32 length (see
+// WriteRecord).
+// Empty records are skipped.
+type StreamSampleSource struct {
+	// Paths lists the files to read.
+	Paths []string
+
+	// Binary indicates that the files contain
+	// length-prefixed binary records rather than lines.
+	Binary bool
+
+	// Decode converts a mini-batch of records into an
+	// anysgd.SampleList.
+	Decode func(records [][]byte) (anysgd.SampleList, error)
+
+	// BatchSize indicates the number of samples to return
+	// from MiniBatch().
+	BatchSize int
+
+	// ShuffleBuffer is the number of records from which
+	// each mini-batch is randomly drawn.
+	// It is never smaller than the batch size.
+	ShuffleBuffer int
+
+	// Prefetch is the number of records to read ahead.
+	// If 0, DefaultPrefetch is used.
+	Prefetch int
+
+	// Start is the position from which to start reading.
+	// It is typically set to a previous value of Position
+	// when resuming from a checkpoint.
+	Start StreamPosition
+
+	records  chan *streamRecord
+	stop     chan struct{}
+	buffer   []*streamRecord
+	received StreamPosition
+}
+
+type streamRecord struct {
+	Data []byte
+	Pos  StreamPosition
+	Next StreamPosition
+	Err  error
+}
+
+// MiniBatch produces the next mini-batch from the shuffle
+// buffer, starting the background reader if necessary.
+func (s *StreamSampleSource) MiniBatch() (anysgd.SampleList, error) {
+	if s.records == nil {
+		s.startReader()
+	}
+
+	bufSize := s.ShuffleBuffer
+	if bufSize < s.BatchSize {
+		bufSize = s.BatchSize
+	}
+	for len(s.buffer) < bufSize {
+		rec, ok := <-s.records
+		if !ok {
+			return nil, errors.New("stream closed")
+		} else if rec.Err != nil {
+			return nil, essentials.AddCtx("stream samples", rec.Err)
+		}
+		s.buffer = append(s.buffer, rec)
+		s.received = rec.Next
+	}
+
+	records := make([][]byte, s.BatchSize)
+	for i := range records {
+		idx := rand.Intn(len(s.buffer))
+		records[i] = s.buffer[idx].Data
+		s.buffer[idx] = s.buffer[len(s.buffer)-1]
+		s.buffer = s.buffer[:len(s.buffer)-1]
+	}
+	return s.Decode(records)
+}
+
+// SetBatchSize sets s.BatchSize.
+func (s *StreamSampleSource) SetBatchSize(n int) {
+	s.BatchSize = n
+}
+
+// Position returns a position from which reading can be
+// resumed without skipping any records that have not yet
+// been used in a mini-batch.
+//
+// Records which are still in the shuffle buffer will be
+// read again after resuming.
+func (s *StreamSampleSource) Position() StreamPosition {
+	if s.records == nil {
+		return s.Start
+	}
+	res := s.received
+	for _, rec := range s.buffer {
+		if rec.Pos.Less(res) {
+			res = rec.Pos
+		}
+	}
+	return res
+}
+
+// Close stops the background reader.
+// The source should not be used after it is closed.
+func (s *StreamSampleSource) Close() {
+	if s.stop != nil {
+		close(s.stop)
+		for range s.records {
+		}
+		s.stop = nil
+	}
+}
+
+func (s *StreamSampleSource) startReader() {
+	prefetch := s.Prefetch
+	if prefetch == 0 {
+		prefetch = DefaultPrefetch
+	}
+	s.records = make(chan *streamRecord, prefetch)
+	s.stop = make(chan struct{})
+	s.received = s.Start
+	go s.readLoop(s.records, s.stop)
+}
+
+func (s *StreamSampleSource) readLoop(records chan<- *streamRecord, stop <-chan struct{}) {
+	defer close(records)
+	send := func(rec *streamRecord) bool {
+		select {
+		case records <- rec:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	if len(s.Paths) == 0 {
+		send(&streamRecord{Err: errors.New("no paths")})
+		return
+	}
+
+	pos := s.Start
+	for {
+		var found bool
+		for ; pos.File < len(s.Paths); pos.File++ {
+			err := s.readFile(pos, func(rec *streamRecord) bool {
+				found = true
+				return send(rec)
+			})
+			if err == errStopped {
+				return
+			} else if err != nil {
+				send(&streamRecord{Err: err})
+				return
+			}
+			pos.Offset = 0
+		}
+		if !found && pos.Epoch != s.Start.Epoch {
+			send(&streamRecord{Err: errors.New("no records")})
+			return
+		}
+		pos.Epoch++
+		pos.File = 0
+	}
+}
+
+var errStopped = errors.New("stopped")
+
+func (s *StreamSampleSource) readFile(start StreamPosition,
+	f func(rec *streamRecord) bool) error {
+	file, err := os.Open(s.Paths[start.File])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(start.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(file)
+
+	pos := start
+	for {
+		data, size, err := s.readRecord(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		next := pos
+		next.Offset += size
+		if len(data) > 0 {
+			if !f(&streamRecord{Data: data, Pos: pos, Next: next}) {
+				return errStopped
+			}
+		}
+		pos = next
+	}
+}
+
+// readRecord reads a record and returns the number of
+// bytes it occupied in the file.
+func (s *StreamSampleSource) readRecord(r *bufio.Reader) ([]byte, int64, error) {
+	if s.Binary {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, 0, err
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, err
+		}
+		return data, int64(size) + 4, nil
+	}
+
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	size := int64(len(line))
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line, size, nil
+}
+
+// WriteRecord writes a binary record in the format used
+// by StreamSampleSource.
+func WriteRecord(w io.Writer, record []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(record))); err != nil {
+		return err
+	}
+	_, err := w.Write(record)
+	return err
+}
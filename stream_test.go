@@ -0,0 +1,96 @@
+package leea
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+type byteSampleList [][]byte
+
+func (b byteSampleList) Len() int {
+	return len(b)
+}
+
+func (b byteSampleList) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+func (b byteSampleList) Slice(i, j int) anysgd.SampleList {
+	return append(byteSampleList{}, b[i:j]...)
+}
+
+func TestStreamSampleSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leea_stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	textPaths := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	ioutil.WriteFile(textPaths[0], []byte("a\nb\n\nc\r\n"), 0644)
+	ioutil.WriteFile(textPaths[1], []byte("d\ne"), 0644)
+
+	binPath := filepath.Join(dir, "c.bin")
+	f, err := os.Create(binPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range []string{"a", "b", "c", "d", "e"} {
+		if err := WriteRecord(f, []byte(rec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	for _, binary := range []bool{false, true} {
+		paths := textPaths
+		if binary {
+			paths = []string{binPath}
+		}
+		newSource := func(start StreamPosition) *StreamSampleSource {
+			return &StreamSampleSource{
+				Paths:  paths,
+				Binary: binary,
+				Decode: func(r [][]byte) (anysgd.SampleList, error) {
+					return byteSampleList(r), nil
+				},
+				BatchSize: 2,
+				Start:     start,
+			}
+		}
+		readBatch := func(s *StreamSampleSource) []string {
+			batch, err := s.MiniBatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var res []string
+			for _, x := range batch.(byteSampleList) {
+				res = append(res, string(x))
+			}
+			sort.Strings(res)
+			return res
+		}
+
+		source := newSource(StreamPosition{})
+		expected := [][]string{{"a", "b"}, {"c", "d"}, {"a", "e"}}
+		for i, exp := range expected {
+			if i == 2 {
+				// Resume from a checkpoint.
+				pos := source.Position()
+				source.Close()
+				source = newSource(pos)
+			}
+			actual := readBatch(source)
+			if len(actual) != 2 || actual[0] != exp[0] || actual[1] != exp[1] {
+				t.Errorf("binary=%v batch %d: expected %v but got %v", binary, i,
+					exp, actual)
+			}
+		}
+		source.Close()
+	}
+}
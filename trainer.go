@@ -21,14 +21,26 @@ const (
 // A Trainer uses LEEA to train artificial neural nets or
 // other parameterized models.
 type Trainer struct {
-	Evaluator  Evaluator
-	Samples    SampleSource
-	Fetcher    anysgd.Fetcher
+	Evaluator Evaluator
+
+	// Samples and Fetcher produce the batch for each
+	// evaluation.
+	// If Samples is nil, evaluators are given nil batches,
+	// which is useful for objectives that do not depend on
+	// any data.
+	Samples SampleSource
+	Fetcher anysgd.Fetcher
+
 	Population []*FitEntity
 	Selector   Selector
 	Mutator    Mutator
 	Crosser    Crosser
 
+	// Mater chooses cross-over partners among the
+	// individuals that have not yet been crossed over.
+	// If nil, a RandomMater is used.
+	Mater Mater
+
 	// DecaySchedule determines how much weight decay should
 	// be applied for a given generation.
 	// If this is nil, no weight decay is applied.
@@ -52,18 +64,116 @@ type Trainer struct {
 
 	// Elitism specifies the number of individuals who are
 	// untouched by mutation and cross-over.
+	//
+	// Elitism is ignored when AgeLayers or Speciation is
+	// set.
 	Elitism int
 
+	// ConfidenceElitism, if non-zero, causes elites to be
+	// chosen by the lower confidence bound of their
+	// fitnesses rather than by their running fitnesses.
+	// The value is the number of standard errors to
+	// subtract from the running fitness.
+	//
+	// Individuals without a measured variance of their own
+	// use the mean measured variance of the population or,
+	// if there is none, the variance of the running
+	// fitnesses across the population.
+	// See FitEntity.LowerBound.
+	ConfidenceElitism float64
+
+	// Reevaluations is the number of extra batches on which
+	// the most fit individuals are evaluated every
+	// generation, reducing the noise in their fitnesses.
+	Reevaluations int
+
+	// ReevalCount is the number of individuals which are
+	// re-evaluated when Reevaluations is non-zero.
+	//
+	// If this is 0, Elitism is used.
+	ReevalCount int
+
+	// BatchSizer, if non-nil, determines the mini-batch
+	// size for each generation.
+	// If it is set, Samples must be a BatchResizer.
+	BatchSizer BatchSizer
+
+	// FeedbackCount is the number of randomly chosen
+	// individuals used to compute per-sample feedback when
+	// Samples is a FeedbackSource.
+	// Every individual is scored from its per-sample
+	// fitnesses in either case, so this only affects which
+	// fitnesses are averaged.
+	//
+	// If this is 0, the entire population is used.
+	FeedbackCount int
+
+	// BatchWindow, if non-zero, is the number of recent
+	// mini-batches to keep in a rolling window.
+	// Every individual is evaluated on the same batches
+	// from the window, so that parents and children are
+	// compared on equal data.
+	//
+	// Scores are cached by entity version (see Versioned),
+	// so that individuals which are neither mutated nor
+	// crossed over are not re-evaluated on old batches.
+	// Entities which are not Versioned are re-evaluated on
+	// every batch each generation.
+	//
+	// Since the window already averages over batches,
+	// Inheritance is often set to 0 when using a window.
+	BatchWindow int
+
+	// WindowSubset, if non-zero, is the number of batches
+	// from the window used each generation.
+	// The subset is chosen randomly each generation but is
+	// shared by every individual, and it always includes
+	// the newest batch.
+	WindowSubset int
+
+	// Validator, if non-nil, is used to validate the
+	// population after it is evaluated.
+	// Evolve stops when the Validator becomes stagnant.
+	Validator *Validator
+
+	// AgeLayers, if non-nil, splits the population into
+	// age layers which evolve mostly independently.
+	AgeLayers *AgeLayers
+
+	// Speciation, if non-nil, splits the population into
+	// species which evolve mostly independently.
+	// It cannot be combined with AgeLayers.
+	Speciation *Speciation
+
+	// Rand, if non-nil, is used for all of the Trainer's
+	// random choices, and it is passed to the Samples,
+	// Selector, Crosser, and Mater if they implement
+	// RandSetter.
+	// Setting it to a generator with a fixed seed makes
+	// training reproducible, provided that the Evaluator,
+	// Mutator, and other components are deterministic.
+	// If nil, the global math/rand generator is used.
+	Rand *rand.Rand
+
 	// Generation is the current generation number.
 	// This starts at 0 and is incremented every time Evolve
 	// goes through another generation.
 	Generation int
+
+	window      []anysgd.Batch
+	windowCache *CachedEvaluator
 }
 
 // FitnessScale is the number by which fitnesses should be
 // divided to get the "running average" fitness.
 // Basically, it accounts for the geometric series with
 // decay rate given by t.Inheritance.
+//
+// This assumes that every entity has lived for the entire
+// run of the Trainer.
+// For an exact, per-entity scale, see FitEntity.Scale.
+// MaxFitness and MeanFitness are already normalized and
+// should not be divided by this.
 func (t *Trainer) FitnessScale() float64 {
 	if t.Generation < 2 {
 		return 1
@@ -86,30 +196,63 @@ func (t *Trainer) FitnessScale() float64 {
 	return sum
 }
 
-// MaxFitness returns the maximum fitness across everyone
-// in the current generation.
+// MaxFitness returns the maximum running average fitness
+// across everyone in the current generation.
 func (t *Trainer) MaxFitness() float64 {
 	m := math.Inf(-1)
 	for _, e := range t.Population {
-		m = math.Max(m, e.Fitness)
+		m = math.Max(m, e.RunningFitness())
 	}
 	return m
 }
 
-// MeanFitness returns the mean fitness.
+// MeanFitness returns the mean running average fitness.
 func (t *Trainer) MeanFitness() float64 {
 	var sum float64
 	for _, e := range t.Population {
-		sum += e.Fitness
+		sum += e.RunningFitness()
 	}
 	return sum / float64(len(t.Population))
 }
 
-// BestEntity returns the entity with maximum fitness.
+// NoiseToSignal estimates how much evaluation noise there
+// is relative to the differences in fitness between
+// individuals.
+//
+// It is the mean variance of each individual's
+// evaluations divided by the variance of the running
+// fitnesses across the population.
+// If the population has no fitness variance, 0 is
+// returned.
+//
+// Evaluation variance comes from inherited fitness and
+// from scoring on several window batches, so the noise is
+// 0 if Inheritance is 0 and BatchWindow is not used.
+func (t *Trainer) NoiseToSignal() float64 {
+	var noise, mean, sqMean float64
+	for _, e := range t.Population {
+		noise += e.FitnessVariance()
+		fit := e.RunningFitness()
+		mean += fit
+		sqMean += fit * fit
+	}
+	n := float64(len(t.Population))
+	noise /= n
+	mean /= n
+	sqMean /= n
+	signal := sqMean - mean*mean
+	if signal <= 0 {
+		return 0
+	}
+	return noise / signal
+}
+
+// BestEntity returns the entity with maximum running
+// average fitness.
 func (t *Trainer) BestEntity() *FitEntity {
 	res := t.Population[0]
 	for _, e := range t.Population[1:] {
-		if e.Fitness > res.Fitness {
+		if e.RunningFitness() > res.RunningFitness() {
 			res = e
 		}
 	}
@@ -118,9 +261,11 @@ func (t *Trainer) BestEntity() *FitEntity {
 
 // Evolve performs evolution.
 // Before every generation, f is called.
-// Evolution stops when f returns false or when the user
-// sends an interrupt signal.
-// This returns an error if fetching samples fails.
+// Evolution stops when f returns false, when the user
+// sends an interrupt signal, or when t.Validator is
+// stagnant.
+// This returns an error if fetching samples, evaluation,
+// or cross-over fails.
 func (t *Trainer) Evolve(f func() bool) error {
 	killSig := rip.NewRIP()
 
@@ -134,6 +279,9 @@ func (t *Trainer) Evolve(f func() bool) error {
 		if err := t.generation(); err != nil {
 			return err
 		}
+		if t.Validator != nil && t.Validator.Stagnant() {
+			return nil
+		}
 	}
 
 	return nil
@@ -143,64 +291,329 @@ func (t *Trainer) generation() error {
 	if len(t.Population) == 0 {
 		return errors.New("no population")
 	}
+	if t.AgeLayers != nil && t.Speciation != nil {
+		return errors.New("cannot combine AgeLayers and Speciation")
+	}
+	if t.Rand != nil {
+		t.setRand()
+	}
 
-	samples, err := t.Samples.MiniBatch()
+	if source, ok := progressSource(t.Samples); ok {
+		source.Progress(t)
+	}
+	if t.BatchSizer != nil {
+		resizer, ok := batchResizer(t.Samples)
+		if !ok {
+			return errors.New("sample source is not a BatchResizer")
+		}
+		if adaptive, ok := t.BatchSizer.(AdaptiveBatchSizer); ok {
+			adaptive.Update(t)
+		}
+		resizer.SetBatchSize(t.BatchSizer.BatchSize(t))
+	}
+
+	batch, err := t.nextBatch()
 	if err != nil {
 		return err
 	}
-	batch, err := t.Fetcher.Fetch(samples)
-	if err != nil {
+	if err := t.evaluateAll(batch); err != nil {
 		return err
 	}
+	if t.Validator != nil {
+		if err := t.Validator.Validate(t); err != nil {
+			return err
+		}
+	}
+
+	var mutate []*FitEntity
+	if t.Speciation != nil {
+		groups, err := t.Speciation.reproduce(t)
+		if err != nil {
+			return err
+		}
+		if err := t.crossOver(batch, groups, t.Speciation.Elitism); err != nil {
+			return err
+		}
+		mutate = t.Speciation.mutationTargets(groups)
+	} else if t.AgeLayers != nil {
+		groups := t.AgeLayers.reproduce(t)
+		if err := t.crossOver(batch, groups, 0); err != nil {
+			return err
+		}
+		mutate = t.Population
+	} else {
+		t.reorderEntities()
+
+		n := t.survivorCount()
+
+		// Overwrite the dead population with the survivors.
+		for i := n; i < len(t.Population); i++ {
+			t.Population[i].set(t.Population[t.rng().Intn(n)])
+		}
+
+		if err := t.crossOver(batch, [][]*FitEntity{t.Population}, t.Elitism); err != nil {
+			return err
+		}
+		mutate = t.Population[t.Elitism:]
+	}
 
+	t.mutateAll(mutate)
 	for _, entity := range t.Population {
-		entity.Fitness *= t.Inheritance
-		entity.Fitness += t.Evaluator.Evaluate(entity.Entity, batch)
+		entity.Age++
+	}
+	if t.AgeLayers != nil {
+		t.AgeLayers.reseed(t)
 	}
-	t.reorderEntities()
+	t.Generation++
 
-	n := t.survivorCount()
+	return nil
+}
 
-	// Overwrite the dead population with the survivors.
-	for i := n; i < len(t.Population); i++ {
-		source := t.Population[rand.Intn(n)]
-		dest := t.Population[i]
-		dest.Entity.Set(source.Entity)
-		dest.Fitness = source.Fitness
+func (t *Trainer) evaluateAll(batch anysgd.Batch) error {
+	evaluator := t.Evaluator
+	if t.BatchWindow > 0 {
+		t.pushWindow(batch)
+		evaluator = t.windowEvaluator()
 	}
 
-	ordering := rand.Perm(len(t.Population))
-	crossOver := t.CrossOverSchedule.ValueAtTime(t.Generation)
-	for i, j := range ordering[:len(ordering)-1] {
-		if j < t.Elitism {
-			continue
+	source, ok := feedbackSource(t.Samples)
+	var samples [][]float64
+	var sampleScores []float64
+	if ok {
+		var err error
+		samples, sampleScores, err = t.evaluateSamples(evaluator, batch)
+		if err != nil {
+			return err
 		}
-		remainingIdxs := ordering[i+1:]
-		otherIdx := remainingIdxs[rand.Intn(len(remainingIdxs))]
-		keepRatio := 1 - crossOver
-		e := t.Population[j]
-		e1 := t.Population[otherIdx]
-		e.Fitness = keepRatio*e.Fitness + (1-keepRatio)*e1.Fitness
-		t.Crosser.Cross(e.Entity, e1.Entity, keepRatio)
 	}
 
-	t.mutateAll()
-	t.Generation++
+	scores := make([][]float64, len(t.Population))
+	if t.BatchWindow > 0 {
+		evals, err := t.windowScores(evaluator, t.windowSubset())
+		if err != nil {
+			return err
+		}
+		for i, score := range evals {
+			scores[i] = []float64{score}
+		}
+	} else {
+		evals := sampleScores
+		if evals == nil {
+			var err error
+			evals, err = t.evaluateEntities(evaluator, t.Population, batch)
+			if err != nil {
+				return err
+			}
+		}
+		for i, score := range evals {
+			scores[i] = []float64{score}
+		}
+	}
+	if source != nil {
+		source.Feedback(t.feedback(samples))
+	}
+	if t.Reevaluations > 0 {
+		if err := t.reevaluate(scores); err != nil {
+			return err
+		}
+	}
+	for i, entity := range t.Population {
+		entity.addEvaluation(t.Inheritance, scores[i])
+	}
+	return nil
+}
+
+// evaluateSamples computes the per-sample fitnesses and
+// the overall score of every individual on a batch.
+func (t *Trainer) evaluateSamples(evaluator Evaluator,
+	batch anysgd.Batch) ([][]float64, []float64, error) {
+	if _, ok := t.Evaluator.(SampleEvaluator); !ok {
+		return nil, nil, errors.New("feedback requires a SampleEvaluator")
+	}
+	samples := make([][]float64, len(t.Population))
+	scores := make([]float64, len(t.Population))
+	for i, e := range t.Population {
+		var err error
+		scores[i], samples[i], err = evaluateSamples(evaluator, e.Entity, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return samples, scores, nil
+}
+
+// feedback averages the per-sample fitnesses of some
+// individuals, as determined by FeedbackCount.
+func (t *Trainer) feedback(samples [][]float64) []float64 {
+	if t.FeedbackCount != 0 && t.FeedbackCount < len(samples) {
+		var subset [][]float64
+		for _, i := range t.rng().Perm(len(samples))[:t.FeedbackCount] {
+			subset = append(subset, samples[i])
+		}
+		samples = subset
+	}
+	var mean []float64
+	for _, fits := range samples {
+		if mean == nil {
+			mean = make([]float64, len(fits))
+		}
+		for i, x := range fits {
+			mean[i] += x / float64(len(samples))
+		}
+	}
+	return mean
+}
+
+// reevaluate evaluates the most promising individuals on
+// extra batches, adding the results to their scores.
+func (t *Trainer) reevaluate(scores [][]float64) error {
+	count := t.ReevalCount
+	if count == 0 {
+		count = t.Elitism
+	}
+	if count > len(t.Population) {
+		count = len(t.Population)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// Rank entities by their fitnesses after this
+	// generation's first evaluation.
+	fitnesses := make([]float64, len(t.Population))
+	for i, entity := range t.Population {
+		e := *entity
+		e.addEvaluation(t.Inheritance, scores[i])
+		fitnesses[i] = e.RunningFitness()
+	}
+	ranking := t.rng().Perm(len(t.Population))
+	sort.Slice(ranking, func(i, j int) bool {
+		return fitnesses[ranking[i]] > fitnesses[ranking[j]]
+	})
+
+	for i := 0; i < t.Reevaluations; i++ {
+		batch, err := t.nextBatch()
+		if err != nil {
+			return err
+		}
+		var top []*FitEntity
+		for _, idx := range ranking[:count] {
+			top = append(top, t.Population[idx])
+		}
+		evals, err := t.evaluateEntities(t.Evaluator, top, batch)
+		if err != nil {
+			return err
+		}
+		for i, score := range evals {
+			idx := ranking[i]
+			scores[idx] = append(scores[idx], score)
+		}
+	}
 
 	return nil
 }
 
-func (t *Trainer) mutateAll() {
+// nextBatch fetches the next mini-batch, or returns nil
+// if there is no sample source.
+func (t *Trainer) nextBatch() (anysgd.Batch, error) {
+	if t.Samples == nil {
+		return nil, nil
+	}
+	samples, err := t.Samples.MiniBatch()
+	if err != nil {
+		return nil, err
+	}
+	return t.Fetcher.Fetch(samples)
+}
+
+// evaluateEntities evaluates entities on a batch, using
+// a PopulationEvaluator when every entity supports it.
+func (t *Trainer) evaluateEntities(evaluator Evaluator, population []*FitEntity,
+	batch anysgd.Batch) ([]float64, error) {
+	entities := make([]Entity, len(population))
+	for i, e := range population {
+		entities[i] = e.Entity
+	}
+	if pe, ok := evaluator.(PopulationEvaluator); ok && pe.CanEvaluateAll(entities, batch) {
+		return pe.EvaluateAll(entities, batch), nil
+	}
+	res := make([]float64, len(entities))
+	for i, e := range entities {
+		var err error
+		res[i], err = evaluate(evaluator, e, batch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// crossOver performs cross-over between members of each
+// group, leaving the first elite members of every group
+// untouched.
+func (t *Trainer) crossOver(batch anysgd.Batch, groups [][]*FitEntity, elite int) error {
+	if bc, ok := t.Crosser.(BatchCrosser); ok {
+		bc.SetBatch(batch)
+	}
+	crossOver := t.CrossOverSchedule.ValueAtTime(t.Generation)
+	keepRatio := 1 - crossOver
+	mater := t.Mater
+	if mater == nil {
+		mater = &RandomMater{rng: t.rng()}
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		ordering := t.rng().Perm(len(group))
+		shuffled := make([]*FitEntity, len(group))
+		for i, j := range ordering {
+			shuffled[i] = group[j]
+		}
+		for i, j := range ordering[:len(ordering)-1] {
+			if j < elite {
+				continue
+			}
+			e := group[j]
+			e1 := mater.Mate(e, shuffled[i+1:])
+			if e1 == nil {
+				continue
+			}
+			e.Fitness = keepRatio*e.Fitness + (1-keepRatio)*e1.Fitness
+			e.Scale = keepRatio*e.Scale + (1-keepRatio)*e1.Scale
+			e.SqFitness = keepRatio*e.SqFitness + (1-keepRatio)*e1.SqFitness
+			if e1.Age > e.Age {
+				e.Age = e1.Age
+			}
+			if e1.Evals > e.Evals {
+				e.Evals = e1.Evals
+			}
+			if err := cross(t.Crosser, e.Entity, e1.Entity, keepRatio); err != nil {
+				return err
+			}
+			touchEntity(e.Entity)
+		}
+	}
+	return nil
+}
+
+func (t *Trainer) mutateAll(population []*FitEntity) {
 	decay := 0.0
 	if t.DecaySchedule != nil {
 		decay = t.DecaySchedule.ValueAtTime(t.Generation)
 	}
 
-	entities := make(chan *FitEntity, len(t.Population)-t.Elitism)
-	for _, x := range t.Population[t.Elitism:] {
-		entities <- x
+	// Every entity gets its own seed so that the results
+	// do not depend on scheduling.
+	seeds := make([]int64, len(population))
+	for i := range seeds {
+		seeds[i] = t.rng().Int63()
 	}
-	close(entities)
+	indices := make(chan int, len(population))
+	for i := range population {
+		indices <- i
+	}
+	close(indices)
 
 	// Mutation benefits from parallelism because normal
 	// sampling is expensive.
@@ -209,33 +622,86 @@ func (t *Trainer) mutateAll() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			gen := rand.NewSource(rand.Int63())
-			for e := range entities {
+			for idx := range indices {
+				e := population[idx]
 				if decay != 0 {
 					e.Entity.Decay(decay)
 				}
-				t.Mutator.Mutate(t.Generation, e.Entity, gen)
+				t.Mutator.Mutate(t.Generation, e.Entity, rand.NewSource(seeds[idx]))
+				touchEntity(e.Entity)
 			}
 		}()
 	}
 	wg.Wait()
 }
 
+// setRand passes Rand to the components which can use it.
+func (t *Trainer) setRand() {
+	for s := t.Samples; s != nil; {
+		setRand(s, t.Rand)
+		w, ok := s.(WrapperSource)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	setRand(t.Selector, t.Rand)
+	setRand(t.Crosser, t.Rand)
+	setRand(t.Mater, t.Rand)
+}
+
+func (t *Trainer) rng() rng {
+	return rng{Rand: t.Rand}
+}
+
 func (t *Trainer) reorderEntities() {
 	if t.Elitism > 0 {
-		s := fitnessSorter(t.Population)
-		sort.Sort(s)
+		if t.ConfidenceElitism != 0 {
+			sort.Sort(&boundSorter{
+				fitnessSorter: t.Population,
+				Confidence:    t.ConfidenceElitism,
+				Prior:         t.priorVariance(),
+			})
+		} else {
+			sort.Sort(fitnessSorter(t.Population))
+		}
 	}
-	t.Selector.SetEntities(t.Population[t.Elitism:], t.FitnessScale())
+	t.Selector.SetEntities(t.Population[t.Elitism:], 1)
 	for i := t.Elitism; i < len(t.Population); i++ {
 		t.Population[i] = t.Selector.Select()
 	}
 }
 
+// priorVariance estimates the evaluation variance of
+// individuals which have no measured variance.
+func (t *Trainer) priorVariance() float64 {
+	var sum, mean, sqMean float64
+	var count int
+	for _, e := range t.Population {
+		if v := e.FitnessVariance(); e.Evals >= 2 && v > 0 {
+			sum += v
+			count++
+		}
+		fit := e.RunningFitness()
+		mean += fit
+		sqMean += fit * fit
+	}
+	if count > 0 {
+		return sum / float64(count)
+	}
+	n := float64(len(t.Population))
+	mean /= n
+	return math.Max(0, sqMean/n-mean*mean)
+}
+
 func (t *Trainer) survivorCount() int {
-	numSelect := int(t.SurvivalRatio*float64(len(t.Population)) + 0.5)
+	return t.survivorCountFor(len(t.Population))
+}
+
+func (t *Trainer) survivorCountFor(popSize int) int {
+	numSelect := int(t.SurvivalRatio*float64(popSize) + 0.5)
 	if t.SurvivalRatio == 0 {
-		numSelect = int(DefaultSurvivalRatio*float64(len(t.Population)) + 0.5)
+		numSelect = int(DefaultSurvivalRatio*float64(popSize) + 0.5)
 	}
 	if numSelect == 0 {
 		return 1
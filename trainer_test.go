@@ -0,0 +1,111 @@
+package leea
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// batchEvaluator scores VectorEntity instances by their
+// first component and records the scores computed on
+// every batch.
+type batchEvaluator struct {
+	Scores map[*EpisodeBatch][]float64
+	Order  []*EpisodeBatch
+}
+
+func (b *batchEvaluator) Evaluate(e Entity, batch anysgd.Batch) float64 {
+	if b.Scores == nil {
+		b.Scores = map[*EpisodeBatch][]float64{}
+	}
+	eb := batch.(*EpisodeBatch)
+	if _, ok := b.Scores[eb]; !ok {
+		b.Order = append(b.Order, eb)
+	}
+	score := e.(*VectorEntity).Floats()[0]
+	b.Scores[eb] = append(b.Scores[eb], score)
+	return score
+}
+
+func (b *batchEvaluator) Count() int {
+	var res int
+	for _, scores := range b.Scores {
+		res += len(scores)
+	}
+	return res
+}
+
+func TestTrainerReevaluations(t *testing.T) {
+	evaluator := &batchEvaluator{}
+	trainer := &Trainer{
+		Evaluator:     evaluator,
+		Samples:       &EpisodeSource{},
+		Fetcher:       &EpisodeSource{},
+		Reevaluations: 2,
+		ReevalCount:   3,
+	}
+	for _, x := range []float64{4, 9, 0, 7, 1, 8, 2} {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity([]float64{x}),
+		})
+	}
+	batch, err := trainer.nextBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trainer.evaluateAll(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evaluator.Order) != 3 {
+		t.Fatalf("expected 3 batches but got %d", len(evaluator.Order))
+	}
+	if n := len(evaluator.Scores[evaluator.Order[0]]); n != 7 {
+		t.Errorf("expected 7 evaluations on the first batch but got %d", n)
+	}
+	for _, b := range evaluator.Order[1:] {
+		scores := evaluator.Scores[b]
+		if len(scores) != 3 || scores[0]+scores[1]+scores[2] != 9+8+7 {
+			t.Errorf("expected the top 3 to be re-evaluated but got %v", scores)
+		}
+	}
+	for _, e := range trainer.Population {
+		expected := 1
+		if e.RunningFitness() >= 7 {
+			expected = 3
+		}
+		if e.Evals != expected {
+			t.Errorf("fitness %f: expected %d evals but got %d", e.RunningFitness(),
+				expected, e.Evals)
+		}
+	}
+}
+
+func TestTrainerConfidenceElitism(t *testing.T) {
+	makePopulation := func() []*FitEntity {
+		elite := &FitEntity{Entity: NewVectorEntity([]float64{0})}
+		for i := 0; i < 8; i++ {
+			elite.addEvaluation(1, []float64{1 + 2*float64(i%2)})
+		}
+		newcomer := &FitEntity{Entity: NewVectorEntity([]float64{1})}
+		newcomer.addEvaluation(1, []float64{2.5})
+		return []*FitEntity{newcomer, elite}
+	}
+
+	trainer := &Trainer{
+		Selector:   &SortSelector{},
+		Elitism:    1,
+		Population: makePopulation(),
+	}
+	trainer.reorderEntities()
+	if trainer.Population[0].Evals != 1 {
+		t.Error("without confidence, the newcomer should be the elite")
+	}
+
+	trainer.Population = makePopulation()
+	trainer.ConfidenceElitism = 2
+	trainer.reorderEntities()
+	if trainer.Population[0].Evals != 8 {
+		t.Error("with confidence, the well-measured individual should be the elite")
+	}
+}
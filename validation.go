@@ -0,0 +1,235 @@
+package leea
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// A HallEntry is a snapshot of an entity, stored in a
+// hall of fame along with its validation score.
+type HallEntry struct {
+	Entity     Entity
+	Score      float64
+	Generation int
+}
+
+// A Validator periodically evaluates the fittest entities
+// of a Trainer on held-out data.
+// It keeps a hall of fame containing snapshots of the
+// entities with the best validation scores.
+//
+// Snapshots are made with the Copier interface, so every
+// entity must implement Copier.
+type Validator struct {
+	// Samples is the source of held-out samples.
+	Samples SampleSource
+
+	// Fetcher is used to fetch held-out batches.
+	// If nil, the Trainer's Fetcher is used.
+	Fetcher anysgd.Fetcher
+
+	// Evaluator is used to score entities on held-out
+	// batches.
+	// If nil, the Trainer's Evaluator is used.
+	Evaluator Evaluator
+
+	// Batches is the number of held-out batches to
+	// average over for each validation.
+	// If 0, one batch is used.
+	Batches int
+
+	// Resample indicates that new held-out batches should
+	// be fetched for every validation.
+	// By default, the batches are fetched once and reused,
+	// which makes scores comparable across validations and
+	// lets a CachedEvaluator skip entities which have not
+	// changed.
+	Resample bool
+
+	// Interval is the number of generations between
+	// validations.
+	// If 0, validation is done every generation.
+	Interval int
+
+	// TopK is the number of entities, chosen by running
+	// fitness, to validate.
+	// If 0, only the best entity is validated.
+	TopK int
+
+	// HallSize is the maximum number of entries in the
+	// hall of fame.
+	// If 0, only the best entry is kept.
+	HallSize int
+
+	// Patience is the number of consecutive validations
+	// without an improvement to the hall of fame's best
+	// score before Stagnant returns true.
+	// If 0, Stagnant always returns false.
+	Patience int
+
+	// HallOfFame stores the best snapshots, sorted by
+	// descending validation score.
+	HallOfFame []*HallEntry
+
+	sinceImprovement int
+	batches          []anysgd.Batch
+}
+
+// Validate validates the fittest entities if the current
+// generation is a multiple of the validation interval.
+func (v *Validator) Validate(t *Trainer) error {
+	interval := v.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	if t.Generation%interval != 0 {
+		return nil
+	}
+
+	fetcher := v.Fetcher
+	if fetcher == nil {
+		fetcher = t.Fetcher
+	}
+	evaluator := v.Evaluator
+	if evaluator == nil {
+		evaluator = t.Evaluator
+	}
+
+	batches := v.batches
+	if v.Resample || batches == nil {
+		numBatches := v.Batches
+		if numBatches == 0 {
+			numBatches = 1
+		}
+		batches = nil
+		for i := 0; i < numBatches; i++ {
+			samples, err := v.Samples.MiniBatch()
+			if err != nil {
+				return err
+			}
+			batch, err := fetcher.Fetch(samples)
+			if err != nil {
+				return err
+			}
+			batches = append(batches, batch)
+		}
+		v.batches = batches
+	}
+
+	best := math.Inf(-1)
+	if len(v.HallOfFame) > 0 {
+		best = v.HallOfFame[0].Score
+	}
+
+	sorted := append(fitnessSorter{}, t.Population...)
+	sort.Sort(sorted)
+	topK := v.TopK
+	if topK == 0 {
+		topK = 1
+	}
+	if topK > len(sorted) {
+		topK = len(sorted)
+	}
+	for _, e := range sorted[:topK] {
+		var score float64
+		for _, batch := range batches {
+			s, err := evaluate(evaluator, e.Entity, batch)
+			if err != nil {
+				return err
+			}
+			score += s
+		}
+		score /= float64(len(batches))
+		if err := v.addToHall(e.Entity, score, t.Generation); err != nil {
+			return err
+		}
+	}
+
+	if len(v.HallOfFame) > 0 && v.HallOfFame[0].Score > best {
+		v.sinceImprovement = 0
+	} else {
+		v.sinceImprovement++
+	}
+
+	return nil
+}
+
+// Best returns the entry with the best validation score,
+// or nil if no validation has been done.
+func (v *Validator) Best() *HallEntry {
+	if len(v.HallOfFame) == 0 {
+		return nil
+	}
+	return v.HallOfFame[0]
+}
+
+// Stagnant returns true if the validation score has not
+// improved in v.Patience validations.
+func (v *Validator) Stagnant() bool {
+	return v.Patience > 0 && v.sinceImprovement >= v.Patience
+}
+
+func (v *Validator) addToHall(e Entity, score float64, gen int) error {
+	hallSize := v.HallSize
+	if hallSize == 0 {
+		hallSize = 1
+	}
+	if len(v.HallOfFame) == hallSize && v.HallOfFame[hallSize-1].Score >= score {
+		return nil
+	}
+	if v.inHall(e, score) {
+		return nil
+	}
+
+	copier, ok := e.(Copier)
+	if !ok {
+		return errors.New("validate: entity does not implement Copier")
+	}
+	snapshot, err := copier.Copy()
+	if err != nil {
+		return err
+	}
+
+	entry := &HallEntry{Entity: snapshot, Score: score, Generation: gen}
+	idx := sort.Search(len(v.HallOfFame), func(i int) bool {
+		return v.HallOfFame[i].Score < score
+	})
+	v.HallOfFame = append(v.HallOfFame, nil)
+	copy(v.HallOfFame[idx+1:], v.HallOfFame[idx:])
+	v.HallOfFame[idx] = entry
+	if len(v.HallOfFame) > hallSize {
+		v.HallOfFame = v.HallOfFame[:hallSize]
+	}
+	return nil
+}
+
+// inHall checks if the hall of fame already contains a
+// snapshot of an entity.
+// Snapshots of Versioned entities are matched by version.
+// Other entities are matched by score, and by parameters
+// if they implement anynet.Parameterizer.
+func (v *Validator) inHall(e Entity, score float64) bool {
+	for _, entry := range v.HallOfFame {
+		if ve, ok := e.(Versioned); ok {
+			if entryVe, ok := entry.Entity.(Versioned); ok {
+				if ve.Version() == entryVe.Version() {
+					return true
+				}
+				continue
+			}
+		}
+		if entry.Score != score {
+			continue
+		}
+		_, ok1 := e.(anynet.Parameterizer)
+		_, ok2 := entry.Entity.(anynet.Parameterizer)
+		if !ok1 || !ok2 || parameterDistance(e, entry.Entity) == 0 {
+			return true
+		}
+	}
+	return false
+}
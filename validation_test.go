@@ -0,0 +1,116 @@
+package leea
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+func TestValidatorHallOfFame(t *testing.T) {
+	source := &countingSource{}
+	validator := &Validator{
+		Samples:   source,
+		Fetcher:   &EpisodeSource{},
+		Evaluator: &ObjectiveEvaluator{Objective: func(x []float64) float64 { return x[0] }},
+		TopK:      2,
+		HallSize:  2,
+		Patience:  2,
+	}
+	trainer := &Trainer{}
+	for _, x := range []float64{1, 3, 2} {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity:  NewVectorEntity([]float64{x}),
+			Fitness: x,
+		})
+	}
+
+	if err := validator.Validate(trainer); err != nil {
+		t.Fatal(err)
+	}
+	checkHall(t, validator, 3, 2)
+
+	// Snapshots should not change with the population.
+	trainer.Population[1].Entity.(*VectorEntity).Vector.Scale(
+		trainer.Population[1].Entity.(*VectorEntity).Vector.Creator().MakeNumeric(0))
+	checkHall(t, validator, 3, 2)
+
+	for i := 0; i < 2; i++ {
+		if validator.Stagnant() {
+			t.Fatalf("validation %d: stagnant too early", i)
+		}
+		if err := validator.Validate(trainer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !validator.Stagnant() {
+		t.Error("expected stagnation after two validations without improvement")
+	}
+
+	trainer.Population[0].Entity = NewVectorEntity([]float64{5})
+	trainer.Population[0].Fitness = 5
+	if err := validator.Validate(trainer); err != nil {
+		t.Fatal(err)
+	}
+	checkHall(t, validator, 5, 3)
+	if validator.Stagnant() {
+		t.Error("improvement should reset stagnation")
+	}
+	if source.calls != 1 {
+		t.Errorf("expected one held-out batch but got %d", source.calls)
+	}
+
+	validator.Resample = true
+	if err := validator.Validate(trainer); err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 2 {
+		t.Errorf("expected a new batch when resampling (calls=%d)", source.calls)
+	}
+}
+
+func TestValidatorStaticPopulation(t *testing.T) {
+	validator := &Validator{
+		Samples:   &EpisodeSource{},
+		Fetcher:   &EpisodeSource{},
+		Evaluator: &ObjectiveEvaluator{Objective: func(x []float64) float64 { return x[0] }},
+		TopK:      3,
+		HallSize:  3,
+	}
+	trainer := &Trainer{}
+	for _, x := range []float64{1, 3, 2} {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity:  NewVectorEntity([]float64{x}),
+			Fitness: x,
+		})
+	}
+	for i := 0; i < 3; i++ {
+		if err := validator.Validate(trainer); err != nil {
+			t.Fatal(err)
+		}
+		checkHall(t, validator, 3, 2, 1)
+	}
+}
+
+func checkHall(t *testing.T, v *Validator, scores ...float64) {
+	if len(v.HallOfFame) != len(scores) {
+		t.Fatalf("expected %d entries but got %d", len(scores), len(v.HallOfFame))
+	}
+	for i, score := range scores {
+		entry := v.HallOfFame[i]
+		value := entry.Entity.(*VectorEntity).Floats()[0]
+		if entry.Score != score || value != score {
+			t.Errorf("entry %d: expected %f but got score %f with value %f", i, score,
+				entry.Score, value)
+		}
+	}
+}
+
+type countingSource struct {
+	EpisodeSource
+	calls int
+}
+
+func (c *countingSource) MiniBatch() (anysgd.SampleList, error) {
+	c.calls++
+	return c.EpisodeSource.MiniBatch()
+}
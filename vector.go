@@ -0,0 +1,150 @@
+package leea
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+// A VectorEntity is an Entity whose parameters are a
+// single vector, making it possible to optimize arbitrary
+// objective functions.
+//
+// It implements anynet.Parameterizer, so it works with
+// AddMutator and SetMutator.
+type VectorEntity struct {
+	Vector anyvec.Vector
+
+	version uint64
+}
+
+// NewVectorEntity creates a VectorEntity with a copy of
+// the data.
+func NewVectorEntity(data []float64) *VectorEntity {
+	c := anyvec64.DefaultCreator{}
+	return &VectorEntity{Vector: c.MakeVectorData(c.MakeNumericList(data))}
+}
+
+// Floats returns a copy of the vector's components.
+func (v *VectorEntity) Floats() []float64 {
+	return numericFloats(v.Vector.Data())
+}
+
+// Parameters returns a variable for the vector.
+func (v *VectorEntity) Parameters() []*anydiff.Var {
+	return []*anydiff.Var{{Vector: v.Vector}}
+}
+
+// Decay scales the vector by 1-r.
+func (v *VectorEntity) Decay(r float64) {
+	v.Vector.Scale(v.Vector.Creator().MakeNumeric(1 - r))
+	v.Touch()
+}
+
+// Set copies the vector and version from e1.
+func (v *VectorEntity) Set(e1 Entity) {
+	v1 := e1.(*VectorEntity)
+	v.Vector.Set(v1.Vector)
+	atomic.StoreUint64(&v.version, v1.Version())
+}
+
+// Copy creates a deep copy of the entity.
+func (v *VectorEntity) Copy() (Entity, error) {
+	return &VectorEntity{Vector: v.Vector.Copy(), version: v.Version()}, nil
+}
+
+// Version returns the version of the vector.
+func (v *VectorEntity) Version() uint64 {
+	if version := atomic.LoadUint64(&v.version); version != 0 {
+		return version
+	}
+	atomic.CompareAndSwapUint64(&v.version, 0, nextVersion())
+	return atomic.LoadUint64(&v.version)
+}
+
+// Touch gives the entity a new version.
+func (v *VectorEntity) Touch() {
+	atomic.StoreUint64(&v.version, nextVersion())
+}
+
+// An ObjectiveEvaluator is an Evaluator which scores a
+// VectorEntity with an objective function.
+// The batch is ignored.
+type ObjectiveEvaluator struct {
+	Objective func(x []float64) float64
+
+	// Minimize indicates that the objective should be
+	// minimized rather than maximized.
+	Minimize bool
+}
+
+// Evaluate computes the objective.
+// The entity must be a *VectorEntity.
+func (o *ObjectiveEvaluator) Evaluate(e Entity, b anysgd.Batch) float64 {
+	res, err := o.EvaluateErr(e, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// EvaluateErr computes the objective.
+func (o *ObjectiveEvaluator) EvaluateErr(e Entity, b anysgd.Batch) (float64, error) {
+	v, ok := e.(*VectorEntity)
+	if !ok {
+		return 0, fmt.Errorf("evaluate objective: unsupported entity: %T", e)
+	}
+	res := o.Objective(v.Floats())
+	if o.Minimize {
+		res = -res
+	}
+	return res, nil
+}
+
+// A UniformCrosser performs cross-over on individual
+// parameters, taking each one from either the destination
+// or the source.
+//
+// Both entities must implement anynet.Parameterizer.
+type UniformCrosser struct {
+	rng rng
+}
+
+// SetRand sets the random number generator.
+func (u *UniformCrosser) SetRand(r *rand.Rand) {
+	u.rng.Rand = r
+}
+
+// Cross performs cross-over.
+func (u *UniformCrosser) Cross(dest, source Entity, keep float64) {
+	defer touchEntity(dest)
+	srcParams := entityParameters(source)
+	for i, p := range entityParameters(dest) {
+		d := p.Vector
+		s := srcParams[i].Vector.Copy()
+		keepDest := d.Creator().MakeVector(d.Len())
+		anyvec.Rand(keepDest, anyvec.Uniform, u.rng.Rand)
+		anyvec.GreaterThan(keepDest, keepDest.Creator().MakeNumeric(1-keep))
+		takeSrc := keepDest.Copy()
+		anyvec.Complement(takeSrc)
+		d.Mul(keepDest)
+		s.Mul(takeSrc)
+		d.Add(s)
+	}
+}
+
+// entityParameters gets the parameters of an entity which
+// implements anynet.Parameterizer.
+func entityParameters(e Entity) []*anydiff.Var {
+	p, ok := e.(anynet.Parameterizer)
+	if !ok {
+		panic(fmt.Sprintf("entity does not implement anynet.Parameterizer: %T", e))
+	}
+	return p.Parameters()
+}
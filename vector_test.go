@@ -0,0 +1,71 @@
+package leea
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestVectorEntityTrainer(t *testing.T) {
+	sphere := func(x []float64) float64 {
+		var res float64
+		for _, c := range x {
+			res += c * c
+		}
+		return res
+	}
+	trainer := &Trainer{
+		Evaluator:         &ObjectiveEvaluator{Objective: sphere, Minimize: true},
+		Selector:          &TournamentSelector{Size: 3, Prob: 1},
+		Mutator:           &AddMutator{Stddev: &ExpSchedule{Init: 0.5, DecayRate: 0.95}},
+		Crosser:           &UniformCrosser{},
+		CrossOverSchedule: &ExpSchedule{Baseline: 0.5},
+		SurvivalRatio:     0.3,
+		Elitism:           1,
+	}
+	for i := 0; i < 30; i++ {
+		x := make([]float64, 5)
+		for j := range x {
+			x[j] = rand.NormFloat64() * 3
+		}
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity(x),
+		})
+	}
+	for i := 0; i < 60; i++ {
+		if err := trainer.generation(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	best := trainer.BestEntity().Entity.(*VectorEntity).Floats()
+	if value := sphere(best); value > 0.5 {
+		t.Errorf("objective did not get small enough: %f", value)
+	}
+}
+
+func TestVectorCrossers(t *testing.T) {
+	for _, keep := range []float64{0, 0.3, 1} {
+		dest := NewVectorEntity([]float64{1, 1, 1, 1, 1, 1, 1, 1})
+		source := NewVectorEntity([]float64{3, 3, 3, 3, 3, 3, 3, 3})
+		(&ArithmeticCrosser{}).Cross(dest, source, keep)
+		for _, x := range dest.Floats() {
+			if math.Abs(x-(keep+3*(1-keep))) > 1e-8 {
+				t.Errorf("arithmetic keep %f: unexpected value %f", keep, x)
+			}
+		}
+
+		dest = NewVectorEntity([]float64{1, 1, 1, 1, 1, 1, 1, 1})
+		(&UniformCrosser{}).Cross(dest, source, keep)
+		var kept int
+		for _, x := range dest.Floats() {
+			if x == 1 {
+				kept++
+			} else if x != 3 {
+				t.Errorf("uniform keep %f: unexpected value %f", keep, x)
+			}
+		}
+		if (keep == 0 && kept != 0) || (keep == 1 && kept != 8) {
+			t.Errorf("uniform keep %f: kept %d values", keep, kept)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package leea
+
+import (
+	"reflect"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+)
+
+// CanEvaluateAll returns true if the entities are
+// *NetEntity instances wrapping anynet.Net objects with
+// the same architecture, the batch is an *anyff.Batch,
+// and MaskZero is not set.
+//
+// Supported nets start with an *anynet.FC, followed by
+// any number of *anynet.FC layers and element-wise
+// anynet.Activation layers.
+// These may be followed by layers without parameters,
+// such as anynet.LogSoftmax.
+func (n *NegCost) CanEvaluateAll(entities []Entity, b anysgd.Batch) bool {
+	if _, ok := b.(*anyff.Batch); !ok || len(entities) == 0 || n.MaskZero {
+		return false
+	}
+	var first anynet.Net
+	for i, e := range entities {
+		netEntity, ok := e.(*NetEntity)
+		if !ok {
+			return false
+		}
+		net, ok := netEntity.Parameterizer.(anynet.Net)
+		if !ok {
+			return false
+		}
+		if i == 0 {
+			if !stackableNet(net) {
+				return false
+			}
+			first = net
+		} else if !sameArchitecture(first, net) {
+			return false
+		}
+	}
+	return true
+}
+
+// EvaluateAll computes the negative cost of every entity
+// by stacking the weights of the networks and running
+// them as batched matrix multiplications.
+//
+// The entities must satisfy CanEvaluateAll.
+func (n *NegCost) EvaluateAll(entities []Entity, b anysgd.Batch) []float64 {
+	chunk := n.MaxStack
+	if chunk == 0 {
+		chunk = len(entities)
+	}
+	var res []float64
+	for i := 0; i < len(entities); i += chunk {
+		end := i + chunk
+		if end > len(entities) {
+			end = len(entities)
+		}
+		var nets []anynet.Net
+		for _, e := range entities[i:end] {
+			nets = append(nets, e.(*NetEntity).Parameterizer.(anynet.Net))
+		}
+		res = append(res, n.evaluateStack(nets, b.(*anyff.Batch))...)
+	}
+	return res
+}
+
+// evaluateStack applies the nets to a batch and computes
+// their negative costs.
+//
+// Activations are stored in a transposed layout: for each
+// net, there is a row-major matrix with one row per
+// output and one column per sample.
+func (n *NegCost) evaluateStack(nets []anynet.Net, batch *anyff.Batch) []float64 {
+	c := batch.Inputs.Output().Creator()
+	numNets := len(nets)
+	numHead := stackHeadSize(nets[0])
+
+	var act anyvec.Vector
+	var actRows int
+	for i, layer := range nets[0][:numHead] {
+		switch layer := layer.(type) {
+		case *anynet.FC:
+			var weights, biases []anyvec.Vector
+			for _, net := range nets {
+				fc := net[i].(*anynet.FC)
+				weights = append(weights, fc.Weights.Vector)
+				biases = append(biases, fc.Biases.Vector)
+			}
+			out := c.MakeVector(numNets * layer.OutCount * batch.Num)
+			if act == nil {
+				// All of the nets share the same inputs, so
+				// the first layer is one big product.
+				anyvec.Gemm(false, true, numNets*layer.OutCount, batch.Num,
+					layer.InCount, c.MakeNumeric(1), c.Concat(weights...),
+					layer.InCount, batch.Inputs.Output(), layer.InCount,
+					c.MakeNumeric(0), out, batch.Num)
+			} else {
+				anyvec.BatchedGemm(false, false, numNets, layer.OutCount, batch.Num,
+					layer.InCount, c.MakeNumeric(1), c.Concat(weights...), act,
+					c.MakeNumeric(0), out)
+			}
+			anyvec.AddChunks(out, c.Concat(biases...))
+			act, actRows = out, layer.OutCount
+		case anynet.Activation:
+			act = layer.Apply(anydiff.NewConst(act), 1).Output()
+		}
+	}
+
+	// Switch to the usual layout of one row per sample.
+	mapping := make([]int, 0, act.Len())
+	for net := 0; net < numNets; net++ {
+		offset := net * actRows * batch.Num
+		for sample := 0; sample < batch.Num; sample++ {
+			for row := 0; row < actRows; row++ {
+				mapping = append(mapping, offset+row*batch.Num+sample)
+			}
+		}
+	}
+	rows := c.MakeVector(act.Len())
+	c.MakeMapper(act.Len(), mapping).Map(act, rows)
+
+	numRows := numNets * batch.Num
+	var out anydiff.Res = anydiff.NewConst(rows)
+	for _, layer := range nets[0][numHead:] {
+		out = layer.Apply(out, numRows)
+	}
+
+	desired := make([]anyvec.Vector, numNets)
+	for i := range desired {
+		desired[i] = batch.Outputs.Output()
+	}
+	costs := numericFloats(n.Cost.Cost(anydiff.NewConst(c.Concat(desired...)), out,
+		numRows).Output().Data())
+
+	res := make([]float64, numNets)
+	for i := range res {
+		for _, cost := range costs[i*batch.Num : (i+1)*batch.Num] {
+			res[i] -= cost
+		}
+		res[i] /= float64(batch.Num)
+	}
+	return res
+}
+
+// stackHeadSize returns the number of leading layers of
+// a net which can be evaluated in the stacked layout.
+func stackHeadSize(net anynet.Net) int {
+	for i, layer := range net {
+		switch layer := layer.(type) {
+		case *anynet.FC:
+			continue
+		case anynet.Activation:
+			if layer != anynet.LogSoftmax {
+				continue
+			}
+		}
+		return i
+	}
+	return len(net)
+}
+
+func stackableNet(net anynet.Net) bool {
+	if len(net) == 0 {
+		return false
+	}
+	if _, ok := net[0].(*anynet.FC); !ok {
+		return false
+	}
+	for _, layer := range net[stackHeadSize(net):] {
+		if p, ok := layer.(anynet.Parameterizer); ok && len(p.Parameters()) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sameArchitecture(n1, n2 anynet.Net) bool {
+	if len(n1) != len(n2) {
+		return false
+	}
+	for i, layer := range n1 {
+		switch layer := layer.(type) {
+		case *anynet.FC:
+			fc, ok := n2[i].(*anynet.FC)
+			if !ok || fc.InCount != layer.InCount || fc.OutCount != layer.OutCount {
+				return false
+			}
+		default:
+			if !reflect.DeepEqual(layer, n2[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package leea
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+func TestNegCostEvaluateAll(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	var entities []Entity
+	for i := 0; i < 5; i++ {
+		entities = append(entities, &NetEntity{Parameterizer: anynet.Net{
+			anynet.NewFC(c, 4, 6),
+			anynet.Tanh,
+			anynet.NewFC(c, 6, 3),
+			anynet.LogSoftmax,
+		}})
+	}
+
+	inputs := c.MakeVector(7 * 4)
+	anyvec.Rand(inputs, anyvec.Normal, nil)
+	outputs := c.MakeVector(7 * 3)
+	anyvec.Rand(outputs, anyvec.Uniform, nil)
+	batch := &anyff.Batch{
+		Inputs:  anydiff.NewConst(inputs),
+		Outputs: anydiff.NewConst(outputs),
+		Num:     7,
+	}
+
+	for _, maxStack := range []int{0, 2} {
+		evaluator := &NegCost{Cost: anynet.DotCost{}, MaxStack: maxStack}
+		if !evaluator.CanEvaluateAll(entities, batch) {
+			t.Fatal("expected entities to be supported")
+		}
+		actual := evaluator.EvaluateAll(entities, batch)
+		for i, e := range entities {
+			expected := evaluator.Evaluate(e, batch)
+			if math.Abs(actual[i]-expected) > 1e-8 {
+				t.Errorf("stack %d: entity %d: expected %f but got %f", maxStack,
+					i, expected, actual[i])
+			}
+		}
+	}
+
+	mismatched := append([]Entity{&NetEntity{Parameterizer: anynet.Net{
+		anynet.NewFC(c, 4, 5),
+		anynet.Tanh,
+		anynet.NewFC(c, 5, 3),
+		anynet.LogSoftmax,
+	}}}, entities...)
+	if (&NegCost{Cost: anynet.DotCost{}}).CanEvaluateAll(mismatched, batch) {
+		t.Error("expected mismatched architectures to be unsupported")
+	}
+}
@@ -0,0 +1,72 @@
+package leea
+
+import (
+	"reflect"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+// pushWindow adds a batch to the rolling window, removing
+// the oldest batch if the window is full.
+func (t *Trainer) pushWindow(batch anysgd.Batch) {
+	t.window = append(t.window, batch)
+	if len(t.window) > t.BatchWindow {
+		t.window = append([]anysgd.Batch{}, t.window[len(t.window)-t.BatchWindow:]...)
+	}
+}
+
+// windowSubset chooses the batches from the window on
+// which every entity will be evaluated this generation.
+// The newest batch is always included.
+func (t *Trainer) windowSubset() []anysgd.Batch {
+	if t.WindowSubset == 0 || t.WindowSubset >= len(t.window) {
+		return t.window
+	}
+	newest := len(t.window) - 1
+	res := []anysgd.Batch{t.window[newest]}
+	for _, i := range t.rng().Perm(newest)[:t.WindowSubset-1] {
+		res = append(res, t.window[i])
+	}
+	return res
+}
+
+// windowEvaluator wraps the Evaluator in a cache which
+// remembers the scores of unchanged entities on the
+// batches in the window.
+//
+// Every score worth keeping was computed within the last
+// BatchWindow generations, and each generation computes
+// at most one score per entity per batch, so the cache
+// size is bounded accordingly.
+func (t *Trainer) windowEvaluator() *CachedEvaluator {
+	if t.windowCache == nil || !sameEvaluator(t.windowCache.Evaluator, t.Evaluator) {
+		t.windowCache = &CachedEvaluator{Evaluator: t.Evaluator}
+	}
+	t.windowCache.Batches = t.BatchWindow
+	t.windowCache.Size = len(t.Population) * t.BatchWindow * (t.BatchWindow + 1)
+	return t.windowCache
+}
+
+// windowScores computes the mean score of every entity on
+// some batches from the window.
+func (t *Trainer) windowScores(evaluator Evaluator, batches []anysgd.Batch) ([]float64,
+	error) {
+	res := make([]float64, len(t.Population))
+	for _, b := range batches {
+		scores, err := t.evaluateEntities(evaluator, t.Population, b)
+		if err != nil {
+			return nil, err
+		}
+		for i, score := range scores {
+			res[i] += score / float64(len(batches))
+		}
+	}
+	return res, nil
+}
+
+func sameEvaluator(e1, e2 Evaluator) bool {
+	if reflect.TypeOf(e1) != reflect.TypeOf(e2) || !reflect.TypeOf(e1).Comparable() {
+		return false
+	}
+	return e1 == e2
+}
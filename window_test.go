@@ -0,0 +1,107 @@
+package leea
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet/anysgd"
+)
+
+func TestTrainerWindowRollover(t *testing.T) {
+	trainer := &Trainer{BatchWindow: 3}
+	var batches []anysgd.Batch
+	for i := 0; i < 5; i++ {
+		batch := &EpisodeBatch{Seed: int64(i)}
+		batches = append(batches, batch)
+		trainer.pushWindow(batch)
+	}
+	if len(trainer.window) != 3 {
+		t.Fatalf("expected 3 batches but got %d", len(trainer.window))
+	}
+	for i, b := range trainer.window {
+		if b != batches[i+2] {
+			t.Errorf("batch %d: expected seed %d", i, i+2)
+		}
+	}
+}
+
+func TestTrainerWindowSubset(t *testing.T) {
+	trainer := &Trainer{BatchWindow: 5, WindowSubset: 3}
+	for i := 0; i < 5; i++ {
+		trainer.pushWindow(&EpisodeBatch{Seed: int64(i)})
+	}
+	for trial := 0; trial < 10; trial++ {
+		subset := trainer.windowSubset()
+		if len(subset) != 3 || subset[0] != trainer.window[4] {
+			t.Fatal("subset should have 3 batches starting with the newest")
+		}
+		seen := map[anysgd.Batch]bool{}
+		for _, b := range subset {
+			seen[b] = true
+		}
+		if len(seen) != 3 {
+			t.Fatal("subset contains duplicate batches")
+		}
+	}
+}
+
+func TestTrainerWindowCache(t *testing.T) {
+	evaluator := &batchEvaluator{}
+	trainer := &Trainer{
+		Evaluator:   evaluator,
+		Samples:     &EpisodeSource{},
+		Fetcher:     &EpisodeSource{},
+		BatchWindow: 3,
+	}
+	for _, x := range []float64{1, 2, 3} {
+		trainer.Population = append(trainer.Population, &FitEntity{
+			Entity: NewVectorEntity([]float64{x}),
+		})
+	}
+	step := func() {
+		batch, err := trainer.nextBatch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := trainer.evaluateAll(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectScores := func(ctx string, counts ...int) {
+		for i, count := range counts {
+			if n := len(evaluator.Scores[evaluator.Order[i]]); n != count {
+				t.Errorf("%s: batch %d: expected %d evaluations but got %d", ctx, i,
+					count, n)
+			}
+		}
+	}
+
+	step()
+	step()
+	expectScores("unchanged", 3, 3)
+
+	// Offspring are scored on the whole window.
+	mutated := trainer.Population[0]
+	(&AddMutator{Stddev: &ExpSchedule{Baseline: 1}}).Mutate(0, mutated.Entity,
+		rand.NewSource(1))
+	(&UniformCrosser{}).Cross(trainer.Population[1].Entity, trainer.Population[2].Entity,
+		0.5)
+	step()
+	expectScores("changed", 5, 5, 3)
+	value := mutated.Entity.(*VectorEntity).Floats()[0]
+	if mutated.Fitness != value {
+		t.Errorf("expected fitness %f but got %f", value, mutated.Fitness)
+	}
+
+	// Old batches leave the window and the cache.
+	step()
+	expectScores("rollover", 5, 5, 3, 3)
+	if len(trainer.windowCache.batches) != 3 {
+		t.Errorf("expected 3 cached batches but got %d", len(trainer.windowCache.batches))
+	}
+	for _, b := range trainer.windowCache.batches {
+		if b == evaluator.Order[0] {
+			t.Error("oldest batch is still cached")
+		}
+	}
+}